@@ -0,0 +1,202 @@
+package bpe
+
+import "fmt"
+
+// UnknownByteToken is the special token NewWithAlphabet registers for bytes
+// outside the alphabet
+const UnknownByteToken = "<unk>"
+
+// OutOfAlphabet is a policy for encoding bytes outside a restricted
+// alphabet
+// Decoding is lossy under every policy: the original byte is gone, so
+// Decode yields nothing for it (or the unknown token's name, or the
+// replacement token's bytes). Use EncodeStrict to reject such bytes with an
+// error instead; it fails regardless of the policy.
+type OutOfAlphabet int
+
+const (
+	// UseUnknownToken encodes the byte as the UnknownByteToken special token
+	UseUnknownToken OutOfAlphabet = iota
+
+	// DropByte leaves the byte out of the encoding, as if it weren't in the
+	// text. Its neighbours can then merge with each other, and
+	// EncodeWithOffsets ranges index the text with the byte removed.
+	DropByte
+
+	// ReplaceByte encodes the byte as Tokenizer.ReplacementToken. A byte
+	// token such as "?" takes part in merges like the byte it stands for; a
+	// special token separates its neighbours like the unknown token does.
+	// EncodeWithOffsets ranges only line up with the text for a one-byte or
+	// special replacement. An invalid ReplacementToken falls back to
+	// UseUnknownToken.
+	ReplaceByte
+)
+
+// alphabet maps bytes to base token IDs for a tokenizer whose base
+// vocabulary isn't the full 256 bytes
+// A nil *alphabet means the usual identity mapping (byte b is token b).
+type alphabet struct {
+	ids     [256]int // Base token ID for each byte, or -1 outside the alphabet
+	size    int      // Number of bytes in the alphabet
+	unknown int      // ID of the UnknownByteToken special token, or -1
+}
+
+// NewWithAlphabet creates a tokenizer whose base vocabulary is only the
+// given bytes, numbered from 0 in ascending byte order
+//
+// A smaller base keeps IDs compact: an ASCII-only corpus needs 128 base
+// tokens instead of 256, so the same vocabulary size buys more merges. The
+// tradeoff is that the tokenizer is no longer lossless for arbitrary input.
+// Bytes outside the alphabet are encoded as the UnknownByteToken special
+// token (registered right after the alphabet), which Decode drops or renders
+// by name; set OutOfAlphabet to drop or replace them instead, or use
+// EncodeStrict to report them as an error. During training they act as
+// separators, so no merge ever spans one.
+//
+// Duplicate bytes are ignored. TrainOptions.TargetVocabSize must be greater
+// than the number of bytes in the alphabet.
+func NewWithAlphabet(bytes []byte) *Tokenizer {
+	t := newAlphabetBase(bytes)
+	t.AddSpecialToken(UnknownByteToken)
+	t.rebuildIndex()
+	return t
+}
+
+// newAlphabetBase returns a tokenizer whose vocabulary is just the given
+// bytes, numbered from 0 in ascending byte order
+// The caller registers any special tokens and then calls rebuildIndex.
+func newAlphabetBase(bytes []byte) *Tokenizer {
+	var present [256]bool
+	for _, b := range bytes {
+		present[b] = true
+	}
+
+	t := &Tokenizer{
+		Vocabulary: make([][]byte, 0, len(bytes)),
+		Merges:     []Merge{},
+	}
+	for b := 0; b < 256; b++ {
+		if present[b] {
+			t.Vocabulary = append(t.Vocabulary, []byte{byte(b)})
+			t.VocabSize++
+		}
+	}
+	return t
+}
+
+// EncodeStrict encodes text like Encode but returns an error if text has a
+// byte outside the tokenizer's alphabet instead of encoding it as the
+// unknown token
+// Tokenizers created by New accept every byte, so it never fails for them.
+func (t *Tokenizer) EncodeStrict(text []byte) ([]int, error) {
+	if t.alphabet != nil {
+		for i, b := range t.normalize(text) {
+			if t.alphabet.ids[b] < 0 {
+				return nil, fmt.Errorf("byte 0x%02x at position %d is outside the alphabet", b, i)
+			}
+		}
+	}
+	return t.Encode(text), nil
+}
+
+// alphabetBytes returns the bytes of the base vocabulary in ascending order
+func (t *Tokenizer) alphabetBytes() []byte {
+	bytes := make([]byte, 0, t.baseVocabSize())
+	for b := 0; b < 256; b++ {
+		if t.alphabet == nil || t.alphabet.ids[b] >= 0 {
+			bytes = append(bytes, byte(b))
+		}
+	}
+	return bytes
+}
+
+// baseVocabSize returns the number of base byte tokens
+func (t *Tokenizer) baseVocabSize() int {
+	if t.alphabet == nil {
+		return 256
+	}
+	return t.alphabet.size
+}
+
+// appendByteTokens appends the base token of each byte in text to dst
+// Out-of-alphabet bytes are handled according to t.OutOfAlphabet; under
+// UseUnknownToken they are dropped if the tokenizer has no unknown token.
+func (t *Tokenizer) appendByteTokens(dst []int, text []byte) []int {
+	if t.alphabet == nil {
+		for _, b := range text {
+			dst = append(dst, int(b))
+		}
+		return dst
+	}
+	replacement := t.outOfAlphabetToken()
+	for _, b := range text {
+		if id := t.alphabet.ids[b]; id >= 0 {
+			dst = append(dst, id)
+		} else if replacement >= 0 {
+			dst = append(dst, replacement)
+		}
+	}
+	return dst
+}
+
+// outOfAlphabetToken returns the token Encode emits for a byte outside the
+// alphabet, or -1 if such bytes are dropped
+func (t *Tokenizer) outOfAlphabetToken() int {
+	switch t.OutOfAlphabet {
+	case DropByte:
+		return -1
+	case ReplaceByte:
+		if id := t.ReplacementToken; id >= 0 && id < len(t.Vocabulary) && (len(t.Vocabulary[id]) > 0 || t.IsSpecial(id)) {
+			return id
+		}
+	}
+	return t.alphabet.unknown
+}
+
+// appendTrainingTokens is appendByteTokens for training streams, where an
+// out-of-alphabet byte becomes a chunkBoundary so no pair spans it
+func (t *Tokenizer) appendTrainingTokens(dst []int, text []byte) []int {
+	if t.alphabet == nil {
+		return t.appendByteTokens(dst, text)
+	}
+	for _, b := range text {
+		if id := t.alphabet.ids[b]; id >= 0 {
+			dst = append(dst, id)
+		} else {
+			dst = append(dst, chunkBoundary)
+		}
+	}
+	return dst
+}
+
+// rebuildAlphabet derives the byte-to-token mapping from the vocabulary
+// It is left nil when every byte b is token b, which keeps the common case
+// on the fast identity path.
+func (t *Tokenizer) rebuildAlphabet() {
+	t.alphabet = nil
+	identity := true
+	for b := 0; b < 256; b++ {
+		if id, ok := t.byBytes[string([]byte{byte(b)})]; !ok || id != b {
+			identity = false
+			break
+		}
+	}
+	if identity {
+		return
+	}
+
+	a := &alphabet{unknown: -1}
+	for b := range a.ids {
+		a.ids[b] = -1
+		if id, ok := t.byBytes[string([]byte{byte(b)})]; ok {
+			a.ids[b] = id
+			a.size++
+		}
+	}
+	for id, name := range t.specialTokens {
+		if name == UnknownByteToken {
+			a.unknown = id
+		}
+	}
+	t.alphabet = a
+}
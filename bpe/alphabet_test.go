@@ -0,0 +1,230 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func asciiAlphabet() []byte {
+	alphabet := make([]byte, 128)
+	for i := range alphabet {
+		alphabet[i] = byte(i)
+	}
+	return alphabet
+}
+
+func TestNewWithAlphabet(t *testing.T) {
+	tokenizer := NewWithAlphabet(asciiAlphabet())
+
+	// 128 byte tokens plus the unknown token
+	if tokenizer.VocabSize != 129 {
+		t.Fatalf("Expected vocab size 129, got %d", tokenizer.VocabSize)
+	}
+	if id := tokenizer.SpecialTokens()[UnknownByteToken]; id != 128 {
+		t.Errorf("Expected %s at ID 128, got %d", UnknownByteToken, id)
+	}
+
+	text := []byte("low lower lowest newer newest")
+	if err := tokenizer.Train(text, 150); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens, err := tokenizer.EncodeStrict(text)
+	if err != nil {
+		t.Fatalf("EncodeStrict failed on ASCII text: %v", err)
+	}
+	if !bytes.Equal(tokenizer.Decode(tokens), text) {
+		t.Errorf("Failed to round-trip %q", text)
+	}
+	for _, id := range tokens {
+		if id >= tokenizer.VocabSize {
+			t.Errorf("Token %d is outside the vocabulary", id)
+		}
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestNewWithAlphabetRejectsOutOfAlphabetByte(t *testing.T) {
+	tokenizer := NewWithAlphabet(asciiAlphabet())
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 135); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	text := []byte("aa\x80ab")
+	if _, err := tokenizer.EncodeStrict(text); err == nil {
+		t.Error("Expected EncodeStrict to reject byte 0x80")
+	}
+
+	unknown := tokenizer.SpecialTokens()[UnknownByteToken]
+	tokens, offsets := tokenizer.EncodeWithOffsets(text)
+	found := false
+	for i, id := range tokens {
+		if id == unknown {
+			found = true
+			if offsets[i] != [2]int{2, 3} {
+				t.Errorf("Expected the unknown token to cover [2, 3), got %v", offsets[i])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected Encode to emit the unknown token, got %v", tokens)
+	}
+	if offsets[len(offsets)-1][1] != len(text) {
+		t.Errorf("Expected offsets to cover all %d bytes, got %v", len(text), offsets)
+	}
+}
+
+func TestNewWithAlphabetTrainingSkipsUnknownBytes(t *testing.T) {
+	tokenizer := NewWithAlphabet([]byte("ab"))
+	if err := tokenizer.Train([]byte("a\xffa\xffa\xffab"), 10); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Only "ab" occurs without an out-of-alphabet byte in between
+	if len(tokenizer.Merges) != 1 || tokenizer.Merges[0] != (Merge{First: 0, Second: 1, Result: 3, Count: 1}) {
+		t.Errorf("Expected the single merge (a, b), got %+v", tokenizer.Merges)
+	}
+}
+
+func TestNewWithAlphabetTargetMustExceedAlphabet(t *testing.T) {
+	tokenizer := NewWithAlphabet(asciiAlphabet())
+	if err := tokenizer.Train([]byte("aaaa"), 128); err == nil {
+		t.Error("Expected error for a target no larger than the alphabet")
+	}
+}
+
+func TestNewWithAlphabetPersistence(t *testing.T) {
+	tokenizer := NewWithAlphabet(asciiAlphabet())
+	if err := tokenizer.Train([]byte("low lower lowest"), 140); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	text := []byte("slowest \xe9")
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	fromBinary, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	data, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var fromJSON Tokenizer
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	expected := tokenizer.Encode(text)
+	for name, loaded := range map[string]*Tokenizer{"binary": fromBinary, "JSON": &fromJSON} {
+		if got := loaded.Encode(text); !equalTokens(got, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, got)
+		}
+		if _, err := loaded.EncodeStrict(text); err == nil {
+			t.Errorf("%s: expected the loaded tokenizer to keep the alphabet", name)
+		}
+	}
+}
+
+func TestResetKeepsAlphabet(t *testing.T) {
+	tokenizer := NewWithAlphabet(asciiAlphabet())
+	if err := tokenizer.Train([]byte("low lower lowest"), 140); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer.Reset()
+
+	fresh := NewWithAlphabet(asciiAlphabet())
+	if tokenizer.VocabSize != fresh.VocabSize || len(tokenizer.Merges) != 0 {
+		t.Fatalf("Expected vocab size %d and no merges, got %d and %d", fresh.VocabSize, tokenizer.VocabSize, len(tokenizer.Merges))
+	}
+	if id := tokenizer.SpecialTokens()[UnknownByteToken]; id != 128 {
+		t.Errorf("Expected %s at ID 128 after Reset, got %d", UnknownByteToken, id)
+	}
+}
+
+func TestOutOfAlphabetPolicies(t *testing.T) {
+	// IDs: '?' 0, 'a' 1, 'b' 2, <unk> 3
+	newTokenizer := func() *Tokenizer {
+		tokenizer := NewWithAlphabet([]byte("ab?"))
+		sep := tokenizer.AddSpecialToken("<sep>")
+		if sep != 4 {
+			t.Fatalf("Expected <sep> at ID 4, got %d", sep)
+		}
+		return tokenizer
+	}
+	text := []byte("ab\xffba")
+
+	cases := []struct {
+		name        string
+		policy      OutOfAlphabet
+		replacement int
+		expected    []int
+		decoded     string
+		offsets     [][2]int
+	}{
+		{"unknown token", UseUnknownToken, 0, []int{1, 2, 3, 2, 1}, "abba", [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+		{"drop", DropByte, 0, []int{1, 2, 2, 1}, "abba", [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}}},
+		{"replace with byte", ReplaceByte, 0, []int{1, 2, 0, 2, 1}, "ab?ba", [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+		{"replace with special", ReplaceByte, 4, []int{1, 2, 4, 2, 1}, "abba", [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+		{"invalid replacement", ReplaceByte, 99, []int{1, 2, 3, 2, 1}, "abba", [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+	}
+	for _, tc := range cases {
+		tokenizer := newTokenizer()
+		tokenizer.OutOfAlphabet = tc.policy
+		tokenizer.ReplacementToken = tc.replacement
+
+		tokens, offsets := tokenizer.EncodeWithOffsets(text)
+		if !equalTokens(tokens, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, tokens)
+		}
+		if tokenizer.CountTokens(text) != len(tc.expected) {
+			t.Errorf("%s: expected CountTokens %d, got %d", tc.name, len(tc.expected), tokenizer.CountTokens(text))
+		}
+		// The replaced byte itself can't be recovered
+		if decoded := string(tokenizer.Decode(tokens)); decoded != tc.decoded {
+			t.Errorf("%s: expected to decode %q, got %q", tc.name, tc.decoded, decoded)
+		}
+		if len(offsets) != len(tc.offsets) {
+			t.Errorf("%s: expected offsets %v, got %v", tc.name, tc.offsets, offsets)
+		} else {
+			for i := range offsets {
+				if offsets[i] != tc.offsets[i] {
+					t.Errorf("%s: expected offsets %v, got %v", tc.name, tc.offsets, offsets)
+					break
+				}
+			}
+		}
+		if _, err := tokenizer.EncodeStrict(text); err == nil {
+			t.Errorf("%s: expected EncodeStrict to reject byte 0xff", tc.name)
+		}
+		if got := tokenizer.Clone().Encode(text); !equalTokens(got, tokens) {
+			t.Errorf("%s: expected the clone to keep the policy, got %v", tc.name, got)
+		}
+	}
+}
+
+func TestOutOfAlphabetDropLetsNeighboursMerge(t *testing.T) {
+	tokenizer := NewWithAlphabet([]byte("ab"))
+	if err := tokenizer.Train([]byte("ab ab ab"), 4); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	ab, ok := tokenizer.TokenForBytes([]byte("ab"))
+	if !ok {
+		t.Fatal("Expected \"ab\" to be learned")
+	}
+
+	text := []byte("a\xffb")
+	if tokens := tokenizer.Encode(text); len(tokens) != 3 {
+		t.Errorf("Expected the unknown token to keep a and b apart, got %v", tokens)
+	}
+	tokenizer.OutOfAlphabet = DropByte
+	if tokens := tokenizer.Encode(text); !equalTokens(tokens, []int{ab}) {
+		t.Errorf("Expected [%d] once the byte is dropped, got %v", ab, tokens)
+	}
+}
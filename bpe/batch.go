@@ -0,0 +1,51 @@
+package bpe
+
+import (
+	"runtime"
+	"sync"
+)
+
+// EncodeBatch encodes many texts concurrently using a pool of workers
+// goroutines and returns the results in input order.
+//
+// Encode only reads the tokenizer's vocabulary and merges, so the workers
+// share the tokenizer without locking. The tokenizer must not be trained or
+// otherwise modified while EncodeBatch is running. A workers value below 1
+// uses one worker per available CPU.
+func (t *Tokenizer) EncodeBatch(texts [][]byte, workers int) [][]int {
+	results := make([][]int, len(texts))
+	if len(texts) == 0 {
+		return results
+	}
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(texts) {
+		workers = len(texts)
+	}
+
+	// Build the shared rank table once up front rather than racing to
+	// build it from every worker
+	t.loadRanks()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = t.Encode(texts[i])
+			}
+		}()
+	}
+
+	for i := range texts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,78 @@
+package bpe
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestEncodeBatchPreservesOrder(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateText(4096), 350); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	texts := make([][]byte, 200)
+	for i := range texts {
+		texts[i] = []byte(fmt.Sprintf("document %d: the quick brown fox %d", i, i*i))
+	}
+
+	for _, workers := range []int{0, 1, 4, 500} {
+		results := tokenizer.EncodeBatch(texts, workers)
+		if len(results) != len(texts) {
+			t.Fatalf("workers=%d: expected %d results, got %d", workers, len(texts), len(results))
+		}
+		for i, text := range texts {
+			if !equalTokens(results[i], tokenizer.Encode(text)) {
+				t.Errorf("workers=%d: result %d doesn't match Encode", workers, i)
+			}
+		}
+	}
+}
+
+func TestEncodeBatchEmpty(t *testing.T) {
+	tokenizer := New()
+	if results := tokenizer.EncodeBatch(nil, 4); len(results) != 0 {
+		t.Errorf("Expected no results, got %d", len(results))
+	}
+}
+
+func TestEncodeBatchDoesNotMutateTokenizer(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateText(4096), 350); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	merges := append([]Merge{}, tokenizer.Merges...)
+	vocab := make(map[int][]byte, len(tokenizer.Vocabulary))
+	for id, b := range tokenizer.Vocabulary {
+		vocab[id] = append([]byte{}, b...)
+	}
+	vocabSize := tokenizer.VocabSize
+
+	texts := make([][]byte, 500)
+	for i := range texts {
+		texts[i] = generateText(100 + i)
+	}
+	tokenizer.EncodeBatch(texts, 8)
+
+	if tokenizer.VocabSize != vocabSize {
+		t.Errorf("VocabSize changed from %d to %d", vocabSize, tokenizer.VocabSize)
+	}
+	if len(tokenizer.Merges) != len(merges) {
+		t.Fatalf("Merges changed length from %d to %d", len(merges), len(tokenizer.Merges))
+	}
+	for i := range merges {
+		if tokenizer.Merges[i] != merges[i] {
+			t.Errorf("Merge %d changed from %+v to %+v", i, merges[i], tokenizer.Merges[i])
+		}
+	}
+	if len(tokenizer.Vocabulary) != len(vocab) {
+		t.Fatalf("Vocabulary changed size from %d to %d", len(vocab), len(tokenizer.Vocabulary))
+	}
+	for id, b := range vocab {
+		if !bytes.Equal(tokenizer.Vocabulary[id], b) {
+			t.Errorf("Vocabulary entry %d changed from %q to %q", id, b, tokenizer.Vocabulary[id])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// binaryMagic identifies a tokenizer written by MarshalBinary
+var binaryMagic = [4]byte{'B', 'P', 'E', 'V'}
+
+// binaryVersion is bumped whenever the MarshalBinary layout changes
+const binaryVersion = 1
+
+// MarshalBinary implements encoding.BinaryMarshaler with a compact format
+// for embedding vocabularies in programs
+//
+// Layout (every integer is an unsigned varint):
+//
+//	magic "BPEV" | version
+//	alphabetLength | alphabet bytes (0 for the full byte range)
+//	specialCount | specialCount × (id | length | name)
+//	mergeCount | mergeCount × (first | second)
+//
+// Like the JSON format, base tokens are implicit and the vocabulary is
+// rebuilt by replaying merges. Merge counts are dropped, so loaded merges
+// have Count 0. Merge results are not stored either: each
+// merge takes the lowest ID not used by a base token, special token or
+// earlier merge, which is how training assigns them. A tokenizer whose
+// results are numbered any other way (for example one imported or edited
+// by hand) is rejected; Compact renumbers it into this shape.
+func (t *Tokenizer) MarshalBinary() ([]byte, error) {
+	specialIDs := make([]int, 0, len(t.specialTokens))
+	for id := range t.specialTokens {
+		specialIDs = append(specialIDs, id)
+	}
+	slices.Sort(specialIDs)
+
+	next := nextFreeIDs(t.baseVocabSize(), specialIDs)
+	for i, merge := range t.Merges {
+		if want := next(); merge.Result != want {
+			return nil, fmt.Errorf("merge %d result %d is not the next free ID %d; call Compact first", i, merge.Result, want)
+		}
+	}
+	if size := t.baseVocabSize() + len(t.Merges) + len(specialIDs); t.VocabSize != size {
+		return nil, fmt.Errorf("vocab size %d does not match %d vocabulary entries; call Compact first", t.VocabSize, size)
+	}
+
+	out := append([]byte{}, binaryMagic[:]...)
+	out = binary.AppendUvarint(out, binaryVersion)
+
+	if t.alphabet != nil {
+		alphabet := t.alphabetBytes()
+		out = binary.AppendUvarint(out, uint64(len(alphabet)))
+		out = append(out, alphabet...)
+	} else {
+		out = binary.AppendUvarint(out, 0)
+	}
+
+	out = binary.AppendUvarint(out, uint64(len(specialIDs)))
+	for _, id := range specialIDs {
+		name := t.specialTokens[id]
+		out = binary.AppendUvarint(out, uint64(id))
+		out = binary.AppendUvarint(out, uint64(len(name)))
+		out = append(out, name...)
+	}
+
+	out = binary.AppendUvarint(out, uint64(len(t.Merges)))
+	for _, merge := range t.Merges {
+		out = binary.AppendUvarint(out, uint64(merge.First))
+		out = binary.AppendUvarint(out, uint64(merge.Second))
+	}
+	return out, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for data written by
+// MarshalBinary
+// The vocabulary is validated the same way as UnmarshalJSON; t is left
+// unchanged if data is corrupt.
+func (t *Tokenizer) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || magic != binaryMagic {
+		return errors.New("not a binary BPE tokenizer")
+	}
+	version, err := readUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading version: %w", err)
+	}
+	if version != binaryVersion {
+		return fmt.Errorf("unsupported binary format version %d", version)
+	}
+
+	in := jsonTokenizer{Version: jsonVersion}
+
+	alphabetLength, err := readUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading alphabet: %w", err)
+	}
+	if alphabetLength > 0 {
+		if in.Alphabet, err = readBytes(r, alphabetLength); err != nil {
+			return fmt.Errorf("reading alphabet: %w", err)
+		}
+	}
+
+	specialCount, err := readUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading special tokens: %w", err)
+	}
+	specialIDs := []int{}
+	for i := 0; i < specialCount; i++ {
+		id, err := readUvarint(r)
+		if err != nil {
+			return fmt.Errorf("reading special token %d: %w", i, err)
+		}
+		length, err := readUvarint(r)
+		if err != nil {
+			return fmt.Errorf("reading special token %d: %w", i, err)
+		}
+		name, err := readBytes(r, length)
+		if err != nil {
+			return fmt.Errorf("reading special token %d: %w", i, err)
+		}
+		if in.SpecialTokens == nil {
+			in.SpecialTokens = make(map[string]int)
+		}
+		if _, dup := in.SpecialTokens[string(name)]; dup {
+			return fmt.Errorf("special token %q appears twice", name)
+		}
+		in.SpecialTokens[string(name)] = id
+		specialIDs = append(specialIDs, id)
+	}
+	slices.Sort(specialIDs)
+
+	mergeCount, err := readUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading merges: %w", err)
+	}
+	// Every merge takes at least two bytes, which bounds a corrupt count
+	if mergeCount > r.Len()/2 {
+		return fmt.Errorf("reading merges: %w", io.ErrUnexpectedEOF)
+	}
+	base := 256
+	if len(in.Alphabet) > 0 {
+		base = len(in.Alphabet)
+	}
+	next := nextFreeIDs(base, specialIDs)
+	in.Merges = make([]jsonMerge, mergeCount)
+	for i := range in.Merges {
+		first, err := readUvarint(r)
+		if err != nil {
+			return fmt.Errorf("reading merge %d: %w", i, err)
+		}
+		second, err := readUvarint(r)
+		if err != nil {
+			return fmt.Errorf("reading merge %d: %w", i, err)
+		}
+		in.Merges[i] = jsonMerge{First: first, Second: second, Result: next()}
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%d unexpected bytes after the merges", r.Len())
+	}
+
+	in.VocabSize = base + len(in.Merges) + len(in.SpecialTokens)
+	return t.restore(in)
+}
+
+// nextFreeIDs returns a generator yielding ascending IDs from base,
+// skipping those in the sorted specialIDs
+func nextFreeIDs(base int, specialIDs []int) func() int {
+	id := base
+	return func() int {
+		for len(specialIDs) > 0 && specialIDs[0] <= id {
+			if specialIDs[0] == id {
+				id++
+			}
+			specialIDs = specialIDs[1:]
+		}
+		id++
+		return id - 1
+	}
+}
+
+// readUvarint reads a varint that must fit in an int
+func readUvarint(r io.ByteReader) (int, error) {
+	v, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, err
+	}
+	if v > uint64(^uint32(0)) {
+		return 0, fmt.Errorf("value %d is out of range", v)
+	}
+	return int(v), nil
+}
@@ -0,0 +1,143 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = (*Tokenizer)(nil)
+	_ encoding.BinaryUnmarshaler = (*Tokenizer)(nil)
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	text := generateVariedText(64 * 1024)
+	tokenizer := New()
+	if err := tokenizer.Train(text[:len(text)/2], 600); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// Special tokens in the middle of the merges push later results along
+	tokenizer.AddSpecialToken("<|sep|>")
+	if err := tokenizer.Train(text, 1257); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer.AddSpecialToken("<|endoftext|>")
+	if len(tokenizer.Merges) != 1000 {
+		t.Fatalf("Expected 1000 merges, got %d", len(tokenizer.Merges))
+	}
+
+	data, err := tokenizer.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var loaded Tokenizer
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+
+	if loaded.VocabSize != tokenizer.VocabSize {
+		t.Errorf("Expected vocab size %d, got %d", tokenizer.VocabSize, loaded.VocabSize)
+	}
+	// Counts are left out of the binary format
+	if len(loaded.Merges) != len(tokenizer.Merges) {
+		t.Fatalf("Expected %d merges, got %d", len(tokenizer.Merges), len(loaded.Merges))
+	}
+	for i, merge := range tokenizer.Merges {
+		merge.Count = 0
+		if loaded.Merges[i] != merge {
+			t.Errorf("Merge %d: expected %+v, got %+v", i, merge, loaded.Merges[i])
+		}
+	}
+	for id, expected := range tokenizer.Vocabulary {
+		if !bytes.Equal(loaded.Vocabulary[id], expected) {
+			t.Errorf("Vocabulary entry %d differs: expected %q, got %q", id, expected, loaded.Vocabulary[id])
+		}
+	}
+	for name, id := range tokenizer.SpecialTokens() {
+		if got, ok := loaded.SpecialTokens()[name]; !ok || got != id {
+			t.Errorf("Special token %q: expected %d, got %d", name, id, got)
+		}
+	}
+	if !equalTokens(loaded.Encode(text), tokenizer.Encode(text)) {
+		t.Error("Encoding differs after the round-trip")
+	}
+
+	jsonData, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(data)*5 > len(jsonData) {
+		t.Errorf("Expected binary (%d bytes) to be under a fifth of JSON (%d bytes)", len(data), len(jsonData))
+	}
+}
+
+func TestBinaryAlphabet(t *testing.T) {
+	tokenizer := NewWithAlphabet([]byte("abc "))
+	if err := tokenizer.Train([]byte("abc cab bca abc cab"), 10); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	data, err := tokenizer.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	var loaded Tokenizer
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v", err)
+	}
+	text := []byte("cab abc")
+	if !equalTokens(loaded.Encode(text), tokenizer.Encode(text)) {
+		t.Errorf("Expected %v, got %v", tokenizer.Encode(text), loaded.Encode(text))
+	}
+	if _, err := loaded.EncodeStrict([]byte("abd")); err == nil {
+		t.Error("Expected the alphabet to survive the round-trip")
+	}
+}
+
+func TestMarshalBinaryRejectsSparseIDs(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateText(4*1024), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	sparsen(tokenizer, 10)
+
+	if _, err := tokenizer.MarshalBinary(); err == nil {
+		t.Fatal("Expected an error for non-consecutive merge results")
+	}
+	if err := tokenizer.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if _, err := tokenizer.MarshalBinary(); err != nil {
+		t.Errorf("Expected a compacted tokenizer to marshal, got %v", err)
+	}
+}
+
+func TestUnmarshalBinaryRejectsCorruptData(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateText(4*1024), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	data, err := tokenizer.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	cases := map[string][]byte{
+		"bad magic": append([]byte("JSON"), data[4:]...),
+		"truncated": data[:len(data)-1],
+		"trailing":  append(bytes.Clone(data), 0),
+		// magic | version 1 | no alphabet | no specials | one merge (300, 1)
+		"unknown token": append([]byte("BPEV"), 1, 0, 0, 1, 0xac, 0x02, 1),
+	}
+	for name, corrupt := range cases {
+		loaded := New()
+		if err := loaded.UnmarshalBinary(corrupt); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if loaded.VocabSize != 256 {
+			t.Errorf("%s: expected the tokenizer to be unchanged", name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bpe
+
+// Case-insensitive training (TrainOptions.CaseInsensitive) learns merges
+// from ASCII-lowercased text. After each learned merge it adds two case
+// variants built the same way from the variants of its parts: an
+// all-uppercase one ("TH" + "E" -> "THE") and a capitalized one ("Th" + "e"
+// -> "The"). The variant merges rank right after the lowercase merge, so
+// Encode segments "THE", "The" and "the" identically while every token
+// still decodes to the original bytes. Mixed case like "tHe" gets no
+// variant and falls back to smaller tokens, which is lossless too.
+
+// addCaseVariants adds the uppercase and capitalized variants of a merge
+// learned from lowercased text, stopping once the vocabulary reaches limit,
+// and returns how many merges it added
+// A variant is skipped if its parts aren't both tokens (e.g. they were
+// learned before case-insensitive training) or if a token with its bytes
+// already exists, which covers tokens without letters.
+func (t *Tokenizer) addCaseVariants(pair [2]int, limit int) int {
+	first, second := t.Vocabulary[pair[0]], t.Vocabulary[pair[1]]
+	variants := [2][2][]byte{
+		{asciiUpper(first), asciiUpper(second)},
+		{asciiCapitalize(first), second},
+	}
+
+	added := 0
+	for _, variant := range variants {
+		if t.VocabSize >= limit {
+			break
+		}
+		left, ok := t.TokenForBytes(variant[0])
+		if !ok {
+			continue
+		}
+		right, ok := t.TokenForBytes(variant[1])
+		if !ok {
+			continue
+		}
+		merged := append(append([]byte{}, variant[0]...), variant[1]...)
+		if _, exists := t.TokenForBytes(merged); exists {
+			continue
+		}
+		t.addMerge(left, right)
+		added++
+	}
+	return added
+}
+
+// asciiLower returns a copy of b with ASCII letters lowercased
+// Only ASCII is folded, so byte offsets are unchanged.
+func asciiLower(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// asciiUpper returns a copy of b with ASCII letters uppercased
+func asciiUpper(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// asciiCapitalize returns a copy of b with its first byte uppercased if it
+// is an ASCII letter
+func asciiCapitalize(b []byte) []byte {
+	out := append([]byte{}, b...)
+	if len(out) > 0 && 'a' <= out[0] && out[0] <= 'z' {
+		out[0] -= 'a' - 'A'
+	}
+	return out
+}
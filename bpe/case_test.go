@@ -0,0 +1,82 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrainCaseInsensitive(t *testing.T) {
+	text := []byte("the cat sat on the mat. The end. THE END! the theme is the thing")
+	tokenizer := New()
+	if _, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 320, CaseInsensitive: true}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	// Every casing of "the" splits the same way and decodes to itself
+	shape := func(tokens []int) []int {
+		widths := make([]int, len(tokens))
+		for i, id := range tokens {
+			widths[i] = len(tokenizer.Vocabulary[id])
+		}
+		return widths
+	}
+	lower := tokenizer.EncodeString("the")
+	if len(lower) == 3 {
+		t.Fatalf("Expected \"the\" to use a learned merge, got %v", lower)
+	}
+	for _, word := range []string{"THE", "The"} {
+		tokens := tokenizer.EncodeString(word)
+		if !equalTokens(shape(tokens), shape(lower)) {
+			t.Errorf("%q has token shape %v, expected %v", word, shape(tokens), shape(lower))
+		}
+		if equalTokens(tokens, lower) {
+			t.Errorf("%q encodes to the same IDs as \"the\"", word)
+		}
+		if decoded := tokenizer.DecodeString(tokens); decoded != word {
+			t.Errorf("Expected %q to round-trip, got %q", word, decoded)
+		}
+	}
+
+	// Case folding is for counting only; all text round-trips exactly
+	for _, input := range [][]byte{text, []byte("tHe ThEmE Is ThE tHiNg"), []byte("CAT MAT SAT")} {
+		if decoded := tokenizer.Decode(tokenizer.Encode(input)); !bytes.Equal(decoded, input) {
+			t.Errorf("Expected %q to round-trip, got %q", input, decoded)
+		}
+	}
+}
+
+func TestTrainCaseInsensitiveMatchesLowercaseMerges(t *testing.T) {
+	text := []byte("Hello World hello world HELLO WORLD")
+
+	folded := New()
+	if _, err := folded.TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, CaseInsensitive: true}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	plain := New()
+	if _, err := plain.TrainWithOptions(asciiLower(text), TrainOptions{TargetVocabSize: 300}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Dropping the variants leaves the merges learned from lowercased text
+	var lowercase [][]byte
+	for _, merge := range folded.Merges {
+		tokenBytes := folded.Vocabulary[merge.Result]
+		if bytes.Equal(tokenBytes, asciiLower(tokenBytes)) {
+			lowercase = append(lowercase, tokenBytes)
+		}
+	}
+	for i, tokenBytes := range lowercase {
+		if i >= len(plain.Merges) {
+			break
+		}
+		if expected := plain.Vocabulary[plain.Merges[i].Result]; !bytes.Equal(tokenBytes, expected) {
+			t.Fatalf("Lowercase merge %d is %q, expected %q", i, tokenBytes, expected)
+		}
+	}
+	if folded.VocabSize > 300 {
+		t.Errorf("Expected variants to respect the target, got %d tokens", folded.VocabSize)
+	}
+}
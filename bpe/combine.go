@@ -0,0 +1,56 @@
+package bpe
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MergeVocabulary adds other's merges and special tokens to t, e.g. to
+// combine tokenizers trained separately on different languages
+//
+// other's merges are appended after t's in their original order. Tokens
+// are matched by their bytes, so each merge is re-resolved against t's IDs:
+// a merge whose bytes t already has maps to the existing token instead of
+// adding a duplicate, and only merges producing new bytes take new IDs.
+// Special tokens are registered by name, reusing any t already has.
+//
+// Encoding text either tokenizer was trained on still round-trips. The
+// merged tokenizer generally encodes each corpus close to, but not always
+// exactly like, its original: t's merges now rank first, and a merge of
+// other's whose bytes t reached through different parts is dropped.
+//
+// It returns an error, leaving t unchanged, if other fails Validate or
+// uses a byte outside t's alphabet.
+func (t *Tokenizer) MergeVocabulary(other *Tokenizer) error {
+	if err := other.Validate(); err != nil {
+		return fmt.Errorf("invalid tokenizer to merge: %w", err)
+	}
+
+	// Base tokens map by byte; check them all before changing anything
+	remap := make(map[int]int, other.VocabSize)
+	for id, b := range other.alphabetBytes() {
+		mapped, ok := t.TokenForBytes([]byte{b})
+		if !ok || mapped >= t.baseVocabSize() {
+			return fmt.Errorf("byte 0x%02x is outside the alphabet", b)
+		}
+		remap[id] = mapped
+	}
+
+	for _, merge := range other.Merges {
+		if existing, ok := t.TokenForBytes(other.Vocabulary[merge.Result]); ok {
+			remap[merge.Result] = existing
+			continue
+		}
+		remap[merge.Result] = t.addMerge(remap[merge.First], remap[merge.Second])
+	}
+
+	ids := make([]int, 0, len(other.specialTokens))
+	for id := range other.specialTokens {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		t.AddSpecialToken(other.specialTokens[id])
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package bpe
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMergeVocabularyDisjointCorpora(t *testing.T) {
+	letters := []byte(strings.Repeat("the cat sat on the mat with the hat ", 20))
+	digits := []byte(strings.Repeat("0123-4567-0123-8901-4567+", 20))
+
+	a := New()
+	if err := a.Train(letters, 290); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	b := New()
+	b.AddSpecialToken("<eos>")
+	if err := b.Train(digits, 290); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	merged := a.Clone()
+	if err := merged.MergeVocabulary(b); err != nil {
+		t.Fatalf("MergeVocabulary failed: %v", err)
+	}
+	if err := merged.Validate(); err != nil {
+		t.Fatalf("Merged tokenizer is invalid: %v", err)
+	}
+	if got, expected := len(merged.Merges), len(a.Merges)+len(b.Merges); got != expected {
+		t.Errorf("Expected %d merges from disjoint corpora, got %d", expected, got)
+	}
+	if _, ok := merged.SpecialTokens()["<eos>"]; !ok {
+		t.Error("Expected <eos> to be carried over")
+	}
+
+	// The corpora share no bytes, so each encodes exactly as before
+	if !equalTokens(merged.Encode(letters), a.Encode(letters)) {
+		t.Error("Expected the first corpus to encode as before")
+	}
+	if got, expected := len(merged.Encode(digits)), len(b.Encode(digits)); got != expected {
+		t.Errorf("Expected the second corpus in %d tokens, got %d", expected, got)
+	}
+	for _, corpus := range [][]byte{letters, digits} {
+		if decoded := merged.Decode(merged.Encode(corpus)); !bytes.Equal(decoded, corpus) {
+			t.Errorf("Merged tokenizer failed to round-trip %q", corpus[:20])
+		}
+	}
+}
+
+func TestMergeVocabularyDeduplicates(t *testing.T) {
+	a := New()
+	if err := a.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	b := New()
+	if err := b.Train([]byte("lowly slower"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	merged := a.Clone()
+	if err := merged.MergeVocabulary(a); err != nil {
+		t.Fatalf("MergeVocabulary failed: %v", err)
+	}
+	if merged.VocabSize != a.VocabSize {
+		t.Errorf("Expected merging a copy to add nothing, vocab grew to %d", merged.VocabSize)
+	}
+
+	if err := merged.MergeVocabulary(b); err != nil {
+		t.Fatalf("MergeVocabulary failed: %v", err)
+	}
+	seen := make(map[string]bool)
+	for id, tokenBytes := range merged.Vocabulary {
+		if seen[string(tokenBytes)] {
+			t.Errorf("Token %d duplicates %q", id, tokenBytes)
+		}
+		seen[string(tokenBytes)] = true
+	}
+	for _, tokenBytes := range b.Vocabulary {
+		if !seen[string(tokenBytes)] {
+			t.Errorf("Expected %q from the second tokenizer", tokenBytes)
+		}
+	}
+}
+
+func TestMergeVocabularyRejectsOutsideAlphabet(t *testing.T) {
+	restricted := NewWithAlphabet([]byte("abc "))
+	other := New()
+	if err := other.Train([]byte("xyz xyz"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	before := restricted.VocabSize
+	if err := restricted.MergeVocabulary(other); err == nil {
+		t.Error("Expected an error for bytes outside the alphabet")
+	}
+	if restricted.VocabSize != before {
+		t.Error("Expected the tokenizer to be unchanged after an error")
+	}
+}
+
+func TestMergeVocabularyRejectsCorruptOther(t *testing.T) {
+	other := New()
+	if err := other.Train([]byte("xyz xyz"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	other.Merges[len(other.Merges)-1].Result = 999
+
+	tokenizer := New()
+	if err := tokenizer.MergeVocabulary(other); err == nil {
+		t.Error("Expected an error for a merge result outside the vocabulary")
+	}
+	if tokenizer.VocabSize != 256 {
+		t.Error("Expected the tokenizer to be unchanged after an error")
+	}
+}
@@ -0,0 +1,141 @@
+package bpe
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Compact renumbers tokens so IDs are dense again after the vocabulary has
+// been edited by hand, imported, or pruned
+//
+// Base byte tokens keep their IDs. Merge results are given consecutive IDs
+// right after them in merge order, and special tokens keep their position
+// relative to the merges, so a tokenizer that is already dense is left as
+// it was. Merges and Vocabulary are rewritten to use the new IDs and
+// VocabSize shrinks to match; vocabulary entries that are neither base
+// tokens, merge results nor special tokens are dropped. Token IDs produced
+// before compaction no longer decode correctly.
+//
+// Each merge may only use base tokens and the results of earlier merges;
+// otherwise Compact returns an error and leaves the tokenizer unchanged.
+func (t *Tokenizer) Compact() error {
+	base := t.baseVocabSize()
+	isResult := make(map[int]bool, len(t.Merges))
+	for i, merge := range t.Merges {
+		if merge.Result < base || isResult[merge.Result] || t.IsSpecial(merge.Result) {
+			return fmt.Errorf("merge %d result %d is already in use", i, merge.Result)
+		}
+		isResult[merge.Result] = true
+	}
+
+	// Walk the old IDs in order, handing out new IDs; merges fill their
+	// slots in merge order
+	used := make([]int, 0, len(t.Merges)+len(t.specialTokens))
+	for id := range isResult {
+		used = append(used, id)
+	}
+	for id := range t.specialTokens {
+		if id >= base {
+			used = append(used, id)
+		}
+	}
+	slices.Sort(used)
+
+	remap := make(map[int]int, len(used))
+	for id := range base {
+		remap[id] = id
+	}
+	specials := make(map[int]string, len(t.specialTokens))
+	nextMerge := 0
+	merges := make([]Merge, len(t.Merges))
+	for newID, oldID := range used {
+		newID += base
+		if name, special := t.specialTokens[oldID]; special {
+			specials[newID] = name
+			continue
+		}
+		merge := t.Merges[nextMerge]
+		merge.Result = newID
+		merges[nextMerge] = merge
+		nextMerge++
+	}
+	for id, name := range t.specialTokens {
+		if id < base {
+			specials[id] = name
+		}
+	}
+
+	// Map the merge inputs, which must already have new IDs by now
+	for i, merge := range merges {
+		for _, id := range [2]*int{&merges[i].First, &merges[i].Second} {
+			newID, ok := remap[*id]
+			if !ok {
+				return fmt.Errorf("merge %d uses token %d before it is defined", i, *id)
+			}
+			*id = newID
+		}
+		remap[t.Merges[i].Result] = merge.Result
+	}
+
+	vocabulary := make([][]byte, base+len(used))
+	copy(vocabulary, t.Vocabulary[:base])
+	for id := range specials {
+		vocabulary[id] = []byte{}
+	}
+	for _, merge := range merges {
+		vocabulary[merge.Result] = append(slices.Clip(vocabulary[merge.First]), vocabulary[merge.Second]...)
+	}
+
+	t.Vocabulary = vocabulary
+	t.Merges = merges
+	t.VocabSize = len(vocabulary)
+	t.specialTokens = nil
+	if len(specials) > 0 {
+		t.specialTokens = specials
+	}
+	t.ranks.Store(nil)
+	t.fingerprint.Store(nil)
+	t.rebuildIndex()
+	return nil
+}
+
+// Dedupe removes merges that repeat the (First, Second) pair of an earlier
+// merge and returns how many were removed
+// Files that contain such repeats are rejected by Load and training; use
+// Dedupe to clean up a vocabulary imported or edited some other way. Later
+// merges that used a removed merge's result are switched to the result of
+// the merge it repeated, which has the same bytes (and may make them
+// repeats in turn, which are removed too). The removed results stay in
+// Vocabulary and still decode, but Encode no longer produces them and
+// Validate reports them as orphans; call Compact afterwards to reclaim
+// their IDs.
+func (t *Tokenizer) Dedupe() int {
+	seen := make(map[[2]int]int, len(t.Merges))
+	remap := make(map[int]int)
+	// Build a new slice rather than filtering in place, so a cached rank
+	// table built from the old one is left intact
+	kept := make([]Merge, 0, len(t.Merges))
+	for _, merge := range t.Merges {
+		if id, ok := remap[merge.First]; ok {
+			merge.First = id
+		}
+		if id, ok := remap[merge.Second]; ok {
+			merge.Second = id
+		}
+		pair := [2]int{merge.First, merge.Second}
+		if result, dup := seen[pair]; dup {
+			remap[merge.Result] = result
+			continue
+		}
+		seen[pair] = merge.Result
+		kept = append(kept, merge)
+	}
+
+	removed := len(t.Merges) - len(kept)
+	if removed > 0 {
+		t.Merges = kept
+		t.ranks.Store(nil)
+		t.fingerprint.Store(nil)
+	}
+	return removed
+}
@@ -0,0 +1,169 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// sparsen moves every merge result and special token up by gap IDs,
+// leaving empty vocabulary slots behind
+func sparsen(tokenizer *Tokenizer, gap int) {
+	shift := func(id int) int {
+		if id < 256 {
+			return id
+		}
+		return id + gap
+	}
+
+	vocabulary := make([][]byte, tokenizer.VocabSize+gap)
+	copy(vocabulary, tokenizer.Vocabulary[:256])
+	for id := 256; id < tokenizer.VocabSize; id++ {
+		vocabulary[shift(id)] = tokenizer.Vocabulary[id]
+	}
+	for i, merge := range tokenizer.Merges {
+		tokenizer.Merges[i] = Merge{First: shift(merge.First), Second: shift(merge.Second), Result: shift(merge.Result), Count: merge.Count}
+	}
+	specials := make(map[int]string)
+	for id, name := range tokenizer.specialTokens {
+		specials[shift(id)] = name
+	}
+	tokenizer.specialTokens = specials
+	tokenizer.Vocabulary = vocabulary
+	tokenizer.VocabSize = len(vocabulary)
+	tokenizer.ranks.Store(nil)
+	tokenizer.rebuildIndex()
+}
+
+func TestCompactRenumbersSparseIDs(t *testing.T) {
+	text := []byte("low lower lowest newer newest widest")
+	expected := New()
+	eos := expected.AddSpecialToken("<eos>")
+	if err := expected.Train(text, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokenizer := expected.Clone()
+	sparsen(tokenizer, 50)
+	if err := tokenizer.Validate(); err == nil {
+		t.Fatal("Expected the sparse tokenizer to fail validation")
+	}
+
+	if err := tokenizer.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Fatalf("Compacted tokenizer is invalid: %v", err)
+	}
+	if tokenizer.VocabSize != expected.VocabSize {
+		t.Errorf("Expected vocab size %d, got %d", expected.VocabSize, tokenizer.VocabSize)
+	}
+	if !equalMerges(tokenizer.Merges, expected.Merges) {
+		t.Error("Expected the original merges back")
+	}
+	if id := tokenizer.SpecialTokens()["<eos>"]; id != eos {
+		t.Errorf("Expected <eos> = %d, got %d", eos, id)
+	}
+
+	for _, input := range [][]byte{text, []byte("slowest newer lows")} {
+		tokens := tokenizer.Encode(input)
+		if !equalTokens(tokens, expected.Encode(input)) {
+			t.Errorf("Encoding of %q differs after compaction", input)
+		}
+		if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, input) {
+			t.Errorf("Expected %q, got %q", input, decoded)
+		}
+	}
+}
+
+func TestCompactDenseIsNoOp(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	before := tokenizer.Fingerprint()
+	if err := tokenizer.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if tokenizer.Fingerprint() != before {
+		t.Error("Expected compacting a dense tokenizer to change nothing")
+	}
+}
+
+func TestCompactRejectsForwardReference(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Merges = []Merge{
+		{First: 400, Second: 'a', Result: 300},
+		{First: 'a', Second: 'b', Result: 400},
+	}
+	if err := tokenizer.Compact(); err == nil {
+		t.Error("Expected an error for a merge that uses a later result")
+	}
+	if tokenizer.VocabSize != 256 || len(tokenizer.Merges) != 2 {
+		t.Error("Expected the tokenizer to be unchanged after an error")
+	}
+}
+
+func TestDuplicateMerges(t *testing.T) {
+	tokenizer := New()
+	text := generateText(4 * 1024)
+	if err := tokenizer.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	expected := tokenizer.Encode(text)
+
+	// Repeat the first merge, then build on the repeat with a byte that
+	// isn't in the text
+	first := tokenizer.Merges[0]
+	repeat := tokenizer.addMerge(first.First, first.Second)
+	tokenizer.addMerge(repeat, 0)
+
+	if err := tokenizer.Validate(); err == nil {
+		t.Error("Expected Validate to reject a repeated merge")
+	}
+	if err := tokenizer.Train(text, 400); err == nil {
+		t.Error("Expected Train to reject a repeated merge")
+	}
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := Load(&buf); err == nil {
+		t.Error("Expected Load to reject a repeated merge")
+	}
+	data, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := json.Unmarshal(data, New()); err == nil {
+		t.Error("Expected UnmarshalJSON to reject a repeated merge")
+	}
+
+	if removed := tokenizer.Dedupe(); removed != 1 {
+		t.Fatalf("Expected 1 merge removed, got %d", removed)
+	}
+	last := tokenizer.Merges[len(tokenizer.Merges)-1]
+	if last.First != first.Result || last.Second != 0 {
+		t.Errorf("Expected the last merge to use %d instead of the repeat, got %+v", first.Result, last)
+	}
+	if got := tokenizer.Encode(text); !equalTokens(got, expected) {
+		t.Error("Expected Encode to be unchanged by the cleanup")
+	}
+	if removed := tokenizer.Dedupe(); removed != 0 {
+		t.Errorf("Expected nothing left to remove, got %d", removed)
+	}
+
+	// The removed merge's result is an orphan until Compact reclaims it
+	if err := tokenizer.Validate(); err == nil {
+		t.Error("Expected Validate to report the orphaned token")
+	}
+	if err := tokenizer.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Fatalf("Expected a valid tokenizer after Dedupe and Compact, got %v", err)
+	}
+	if err := tokenizer.Train(text, 400); err != nil {
+		t.Errorf("Expected training to continue after Dedupe, got %v", err)
+	}
+}
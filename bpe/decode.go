@@ -0,0 +1,211 @@
+package bpe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DecodeStrict is like Decode but returns an error naming the first invalid
+// token ID (negative, too large, or unused) instead of skipping or
+// replacing it
+func (t *Tokenizer) DecodeStrict(tokens []int) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := t.DecodeToStrict(&buf, tokens); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeString is Decode returning a string
+// It decodes straight into a strings.Builder, so the text is not copied a
+// second time to convert it.
+func (t *Tokenizer) DecodeString(tokens []int) string {
+	var sb strings.Builder
+	t.DecodeTo(&sb, tokens)
+	return sb.String()
+}
+
+// DecodeUntil decodes tokens like Decode, stopping before the first
+// occurrence of stopID (such as an end-of-sequence special token)
+// Nothing from the stop token onward is decoded. If stopID doesn't occur,
+// the whole sequence is decoded.
+func (t *Tokenizer) DecodeUntil(tokens []int, stopID int) []byte {
+	if i := slices.Index(tokens, stopID); i >= 0 {
+		tokens = tokens[:i]
+	}
+	return t.Decode(tokens)
+}
+
+// DecodeWithOffsets decodes tokens like Decode and also returns, for each
+// token, the [start, end) byte range it occupies in the output
+// The ranges are contiguous and together cover the whole output. A token
+// that writes nothing (a dropped special token, or an invalid ID with
+// UnknownTokenBytes nil) gets an empty range.
+func (t *Tokenizer) DecodeWithOffsets(tokens []int) ([]byte, [][2]int) {
+	result := []byte{}
+	offsets := make([][2]int, len(tokens))
+	for i, tokenID := range tokens {
+		start := len(result)
+		if bytes, ok := t.renderToken(tokenID); ok {
+			result = append(result, bytes...)
+		} else {
+			result = append(result, t.UnknownTokenBytes...)
+		}
+		offsets[i] = [2]int{start, len(result)}
+	}
+	if t.SpaceMarker == 0 {
+		return result, offsets
+	}
+
+	// Map raw positions to positions after markers become spaces; a
+	// boundary inside a marker (split across tokens) moves to its start
+	marker := []byte(string(t.SpaceMarker))
+	remap := make([]int, len(result)+1)
+	out := 0
+	for i := 0; i < len(result); out++ {
+		width := 1
+		if bytes.HasPrefix(result[i:], marker) {
+			width = len(marker)
+		}
+		for j := i; j < i+width; j++ {
+			remap[j] = out
+		}
+		i += width
+	}
+	remap[len(result)] = out
+	for i, span := range offsets {
+		offsets[i] = [2]int{remap[span[0]], remap[span[1]]}
+	}
+	return t.unmarkSpaces(result), offsets
+}
+
+// DecodeDebug renders tokens for human inspection, joining each token's
+// bytes with sep so the boundaries show, e.g. "lo|w|est"
+// Non-printable characters and invalid UTF-8 bytes are escaped Go-style
+// ("\n", "\x00"), and token IDs that aren't in the vocabulary appear as
+// "<invalid N>". Tokens are shown as stored, so a SpaceMarker is not turned
+// back into a space. The output is not meant to be decoded again.
+func (t *Tokenizer) DecodeDebug(tokens []int, sep string) string {
+	var sb strings.Builder
+	for i, tokenID := range tokens {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		tokenBytes, ok := t.renderToken(tokenID)
+		if !ok {
+			fmt.Fprintf(&sb, "<invalid %d>", tokenID)
+			continue
+		}
+		writeEscaped(&sb, tokenBytes)
+	}
+	return sb.String()
+}
+
+// TokenString returns a readable form of a single token for logging
+// Valid UTF-8 is shown as is, with non-printable characters and invalid
+// bytes escaped Go-style ("\n", "\xff") as in DecodeDebug. Special tokens
+// are shown by name whatever RenderSpecialTokens says, and invalid IDs as
+// "<invalid N>".
+func (t *Tokenizer) TokenString(id int) string {
+	if name, ok := t.specialTokens[id]; ok {
+		return name
+	}
+	tokenBytes, ok := t.renderToken(id)
+	if !ok {
+		return fmt.Sprintf("<invalid %d>", id)
+	}
+	var sb strings.Builder
+	writeEscaped(&sb, tokenBytes)
+	return sb.String()
+}
+
+// writeEscaped writes b as text, escaping non-printable characters and
+// bytes that aren't valid UTF-8
+func writeEscaped(sb *strings.Builder, b []byte) {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(sb, "\\x%02x", b[0])
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		default:
+			quoted := strconv.QuoteRuneToASCII(r)
+			sb.WriteString(quoted[1 : len(quoted)-1])
+		}
+		b = b[size:]
+	}
+}
+
+// DecodeToken returns the bytes for a single token ID and whether the ID is
+// valid
+// Unlike Decode([]int{id}) it doesn't allocate: the returned slice is the
+// vocabulary entry itself and must not be modified. Special tokens follow
+// the same RenderSpecialTokens rule as Decode.
+func (t *Tokenizer) DecodeToken(id int) ([]byte, bool) {
+	return t.renderToken(id)
+}
+
+// DecodeTo writes the bytes for each token directly to w
+// Nothing is accumulated in memory, so it suits long generations; the
+// exception is a tokenizer with a SpaceMarker, whose output is collected
+// and written once so markers split across tokens still decode. Invalid
+// token IDs are handled exactly like Decode: replaced with
+// UnknownTokenBytes, or skipped if that is nil. It returns the total number
+// of bytes written and the first write error, if any.
+func (t *Tokenizer) DecodeTo(w io.Writer, tokens []int) (int, error) {
+	return t.decodeTo(w, tokens, false)
+}
+
+// DecodeToStrict is like DecodeTo but stops with an error at the first
+// token ID that isn't in the vocabulary
+// Bytes for the tokens before it have already been written.
+func (t *Tokenizer) DecodeToStrict(w io.Writer, tokens []int) (int, error) {
+	return t.decodeTo(w, tokens, true)
+}
+
+func (t *Tokenizer) decodeTo(w io.Writer, tokens []int, strict bool) (int, error) {
+	if t.SpaceMarker == 0 {
+		return t.writeTokens(w, tokens, strict)
+	}
+
+	// A marker can be split across tokens, so collect the output and
+	// restore spaces before writing it
+	var buf bytes.Buffer
+	_, decodeErr := t.writeTokens(&buf, tokens, strict)
+	n, err := w.Write(t.unmarkSpaces(buf.Bytes()))
+	if err != nil {
+		return n, err
+	}
+	return n, decodeErr
+}
+
+// writeTokens writes each token's bytes to w as they are
+func (t *Tokenizer) writeTokens(w io.Writer, tokens []int, strict bool) (int, error) {
+	written := 0
+	for i, tokenID := range tokens {
+		bytes, ok := t.renderToken(tokenID)
+		if !ok {
+			if strict {
+				return written, fmt.Errorf("invalid token ID %d at position %d", tokenID, i)
+			}
+			bytes = t.UnknownTokenBytes
+		}
+		if len(bytes) == 0 {
+			continue
+		}
+
+		n, err := w.Write(bytes)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,326 @@
+package bpe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDecodeToMatchesDecode(t *testing.T) {
+	tokenizer := New()
+	trainText := []byte("low lower lowest")
+	if err := tokenizer.Train(trainText, 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens := tokenizer.Encode(trainText)
+
+	var buf bytes.Buffer
+	n, err := tokenizer.DecodeTo(&buf, tokens)
+	if err != nil {
+		t.Fatalf("DecodeTo failed: %v", err)
+	}
+
+	expected := tokenizer.Decode(tokens)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("DecodeTo output differs from Decode.\nExpected: %s\nGot: %s", expected, buf.Bytes())
+	}
+	if n != len(expected) {
+		t.Errorf("Expected %d bytes written, got %d", len(expected), n)
+	}
+}
+
+func TestDecodeToSkipsInvalidTokens(t *testing.T) {
+	tokenizer := New()
+	tokens := []int{'h', 999999, 'i'}
+
+	var buf bytes.Buffer
+	n, err := tokenizer.DecodeTo(&buf, tokens)
+	if err != nil {
+		t.Fatalf("DecodeTo failed: %v", err)
+	}
+	if buf.String() != "hi" || n != 2 {
+		t.Errorf("Expected \"hi\" (2 bytes), got %q (%d bytes)", buf.String(), n)
+	}
+}
+
+func TestDecodeToStrictRejectsInvalidTokens(t *testing.T) {
+	tokenizer := New()
+	tokens := []int{'h', 999999, 'i'}
+
+	var buf bytes.Buffer
+	n, err := tokenizer.DecodeToStrict(&buf, tokens)
+	if err == nil || !strings.Contains(err.Error(), "999999") {
+		t.Fatalf("Expected error naming the invalid token, got %v", err)
+	}
+
+	// Tokens before the invalid one have already been written
+	if buf.String() != "h" || n != 1 {
+		t.Errorf("Expected \"h\" (1 byte), got %q (%d bytes)", buf.String(), n)
+	}
+}
+
+type failingWriter struct {
+	remaining int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.remaining <= 0 {
+		return 0, errors.New("disk full")
+	}
+	w.remaining--
+	return len(p), nil
+}
+
+func TestDecodeToReportsWriteErrors(t *testing.T) {
+	tokenizer := New()
+
+	n, err := tokenizer.DecodeTo(&failingWriter{remaining: 2}, []int{'a', 'b', 'c'})
+	if err == nil {
+		t.Fatal("Expected the writer's error to be returned")
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 bytes written before the error, got %d", n)
+	}
+}
+
+func TestDecodeToken(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	b, ok := tokenizer.DecodeToken('a')
+	if !ok || string(b) != "a" {
+		t.Errorf("DecodeToken('a') = %q (valid=%v), expected \"a\"", b, ok)
+	}
+
+	b, ok = tokenizer.DecodeToken(257)
+	if !ok || string(b) != "aaa" {
+		t.Errorf("DecodeToken(257) = %q (valid=%v), expected \"aaa\"", b, ok)
+	}
+
+	if _, ok := tokenizer.DecodeToken(258); ok {
+		t.Error("Expected token 258 to be invalid")
+	}
+	if _, ok := tokenizer.DecodeToken(-1); ok {
+		t.Error("Expected token -1 to be invalid")
+	}
+}
+
+func TestDecodeTokenDoesNotAllocate(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable under the race detector")
+	}
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		tokenizer.DecodeToken(257)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f", allocs)
+	}
+}
+
+func TestDecodeUnknownTokenBytes(t *testing.T) {
+	tokenizer := New()
+	tokenizer.UnknownTokenBytes = []byte("�")
+	tokens := []int{'h', 999999, 'i'}
+
+	expected := "h�i"
+	if decoded := tokenizer.Decode(tokens); string(decoded) != expected {
+		t.Errorf("Expected %q, got %q", expected, decoded)
+	}
+
+	// DecodeTo handles unknown tokens the same way
+	var buf bytes.Buffer
+	if _, err := tokenizer.DecodeTo(&buf, tokens); err != nil {
+		t.Fatalf("DecodeTo failed: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("Expected %q from DecodeTo, got %q", expected, buf.String())
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	decoded, err := tokenizer.DecodeStrict([]int{257, 'b'})
+	if err != nil {
+		t.Fatalf("DecodeStrict failed: %v", err)
+	}
+	if string(decoded) != "aaab" {
+		t.Errorf("Expected \"aaab\", got %q", decoded)
+	}
+
+	// The error names the first invalid ID, even with a replacement configured
+	tokenizer.UnknownTokenBytes = []byte("?")
+	_, err = tokenizer.DecodeStrict([]int{'a', 4242, 5353})
+	if err == nil || !strings.Contains(err.Error(), "4242") {
+		t.Errorf("Expected error naming token 4242, got %v", err)
+	}
+}
+
+func TestDecodeWithOffsets(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+	text := []byte("low lower lowest newer newest")
+	if err := tokenizer.Train(text, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokens := append(tokenizer.Encode(text), eos)
+
+	decoded, offsets := tokenizer.DecodeWithOffsets(tokens)
+	if !bytes.Equal(decoded, tokenizer.Decode(tokens)) {
+		t.Errorf("Expected %q, got %q", tokenizer.Decode(tokens), decoded)
+	}
+	if len(offsets) != len(tokens) {
+		t.Fatalf("Expected %d offsets, got %d", len(tokens), len(offsets))
+	}
+
+	end := 0
+	for i, span := range offsets {
+		if span[0] != end {
+			t.Errorf("Token %d starts at %d, expected %d", i, span[0], end)
+		}
+		if piece := decoded[span[0]:span[1]]; !bytes.Equal(piece, tokenizer.Vocabulary[tokens[i]]) {
+			t.Errorf("Token %d covers %q, expected %q", i, piece, tokenizer.Vocabulary[tokens[i]])
+		}
+		end = span[1]
+	}
+	if end != len(decoded) {
+		t.Errorf("Offsets end at %d, expected %d", end, len(decoded))
+	}
+
+	// Rendered special tokens and unknown IDs get the bytes Decode writes
+	tokenizer.RenderSpecialTokens = true
+	tokenizer.UnknownTokenBytes = []byte("?")
+	decoded, offsets = tokenizer.DecodeWithOffsets([]int{eos, 9999, 'a'})
+	if string(decoded) != "<eos>?a" {
+		t.Errorf("Expected %q, got %q", "<eos>?a", decoded)
+	}
+	if offsets[0] != [2]int{0, 5} || offsets[1] != [2]int{5, 6} || offsets[2] != [2]int{6, 7} {
+		t.Errorf("Unexpected offsets %v", offsets)
+	}
+}
+
+func TestDecodeDebug(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest low lower lowest"), 262); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens := tokenizer.EncodeString("lowest")
+	debug := tokenizer.DecodeDebug(tokens, "|")
+	if strings.Count(debug, "|") != len(tokens)-1 {
+		t.Errorf("Expected %d separators in %q", len(tokens)-1, debug)
+	}
+	if strings.ReplaceAll(debug, "|", "") != "lowest" {
+		t.Errorf("Expected the pieces of %q to spell \"lowest\"", debug)
+	}
+	if len(tokens) < 2 || len(tokens) >= len("lowest") {
+		t.Fatalf("Expected a partially merged encoding, got %v", tokens)
+	}
+
+	// Control characters, invalid UTF-8 and invalid IDs are made visible
+	got := tokenizer.DecodeDebug([]int{'a', '\n', 0, 0xff, 9999}, " ")
+	expected := `a \n \x00 \xff <invalid 9999>`
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if !bytes.Equal(tokenizer.Decode(tokens), []byte("lowest")) {
+		t.Error("DecodeDebug must not affect Decode")
+	}
+}
+
+func TestDecodeUntil(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train([]byte("hello world hello there"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer.RenderSpecialTokens = true
+
+	tokens := append(tokenizer.EncodeString("hello world"), eos)
+	tokens = append(tokens, tokenizer.EncodeString(" garbage")...)
+	tokens = append(tokens, eos)
+
+	if got := tokenizer.DecodeUntil(tokens, eos); string(got) != "hello world" {
+		t.Errorf("Expected %q, got %q", "hello world", got)
+	}
+	if got := tokenizer.DecodeUntil(tokens[:2], eos); !bytes.Equal(got, tokenizer.Decode(tokens[:2])) {
+		t.Errorf("Expected the whole sequence without a stop token, got %q", got)
+	}
+	if got := tokenizer.DecodeUntil([]int{eos, 'a'}, eos); len(got) != 0 {
+		t.Errorf("Expected nothing before a leading stop token, got %q", got)
+	}
+}
+
+func TestDecodeOutOfRangeIDs(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// An empty slot, as left by hand-editing a vocabulary
+	tokenizer.Vocabulary = append(tokenizer.Vocabulary, nil)
+	tokenizer.VocabSize++
+	hole := tokenizer.VocabSize - 1
+
+	for _, id := range []int{-1, math.MinInt, tokenizer.VocabSize, math.MaxInt, hole} {
+		tokens := []int{'a', id, 'b'}
+
+		if got := tokenizer.Decode(tokens); string(got) != "ab" {
+			t.Errorf("ID %d: expected Decode to skip it, got %q", id, got)
+		}
+		if got := tokenizer.DecodeString(tokens); got != "ab" {
+			t.Errorf("ID %d: expected DecodeString to skip it, got %q", id, got)
+		}
+		if _, ok := tokenizer.DecodeToken(id); ok {
+			t.Errorf("ID %d: expected DecodeToken to report it as invalid", id)
+		}
+		_, err := tokenizer.DecodeStrict(tokens)
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprint(id)) {
+			t.Errorf("ID %d: expected DecodeStrict to name it, got %v", id, err)
+		}
+
+		tokenizer.UnknownTokenBytes = []byte("?")
+		if got := tokenizer.Decode(tokens); string(got) != "a?b" {
+			t.Errorf("ID %d: expected the replacement, got %q", id, got)
+		}
+		tokenizer.UnknownTokenBytes = nil
+	}
+}
+
+func TestTokenString(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+	high := tokenizer.AddForcedMerge([]byte("a\xffb"))
+	word := tokenizer.AddForcedMerge([]byte("naïve\n"))
+
+	cases := []struct {
+		id       int
+		expected string
+	}{
+		{high, `a\xffb`},
+		{word, `naïve\n`},
+		{0xff, `\xff`},
+		{'a', "a"},
+		{eos, "<eos>"},
+		{-1, "<invalid -1>"},
+		{tokenizer.VocabSize, fmt.Sprintf("<invalid %d>", tokenizer.VocabSize)},
+	}
+	for _, c := range cases {
+		if got := tokenizer.TokenString(c.id); got != c.expected {
+			t.Errorf("TokenString(%d): expected %q, got %q", c.id, c.expected, got)
+		}
+	}
+}
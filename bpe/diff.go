@@ -0,0 +1,83 @@
+package bpe
+
+// MergeDiff describes how the merges of two tokenizers differ, as returned
+// by DiffMerges
+type MergeDiff struct {
+	// OnlyInA lists merges of a that b doesn't learn at any rank, in a's
+	// merge order
+	OnlyInA []Merge
+	// OnlyInB lists merges of b that a doesn't learn at any rank, in b's
+	// merge order
+	OnlyInB []Merge
+	// Changed lists the ranks where both tokenizers have a merge but not
+	// the same one, in rank order
+	Changed []RankDiff
+}
+
+// RankDiff is a rank at which two tokenizers learned different merges
+type RankDiff struct {
+	Rank int
+	A    Merge
+	B    Merge
+}
+
+// Empty reports whether the two tokenizers learned the same merges in the
+// same order
+func (d MergeDiff) Empty() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+// DiffMerges compares the merges learned by a and b, e.g. to find where two
+// training runs diverged
+//
+// Merges are compared by the bytes of the two tokens they join rather than
+// by token ID, since the same merge gets a different ID in each tokenizer
+// once their merge lists diverge. A merge learned by both at different
+// ranks is reported only in Changed (at each rank where it differs), not in
+// OnlyInA or OnlyInB. The Merge values are each tokenizer's own.
+func DiffMerges(a, b *Tokenizer) MergeDiff {
+	var diff MergeDiff
+	keysA := mergeKeys(a)
+	keysB := mergeKeys(b)
+
+	inA := make(map[[2]string]bool, len(keysA))
+	for _, key := range keysA {
+		inA[key] = true
+	}
+	inB := make(map[[2]string]bool, len(keysB))
+	for _, key := range keysB {
+		inB[key] = true
+	}
+
+	for rank, key := range keysA {
+		if !inB[key] {
+			diff.OnlyInA = append(diff.OnlyInA, a.Merges[rank])
+		}
+		if rank < len(keysB) && keysB[rank] != key {
+			diff.Changed = append(diff.Changed, RankDiff{Rank: rank, A: a.Merges[rank], B: b.Merges[rank]})
+		}
+	}
+	for rank, key := range keysB {
+		if !inA[key] {
+			diff.OnlyInB = append(diff.OnlyInB, b.Merges[rank])
+		}
+	}
+	return diff
+}
+
+// mergeKeys returns the bytes of the two tokens joined by each merge
+// An ID outside the vocabulary contributes no bytes.
+func mergeKeys(t *Tokenizer) [][2]string {
+	tokenBytes := func(id int) string {
+		if id < 0 || id >= len(t.Vocabulary) {
+			return ""
+		}
+		return string(t.Vocabulary[id])
+	}
+
+	keys := make([][2]string, len(t.Merges))
+	for i, merge := range t.Merges {
+		keys[i] = [2]string{tokenBytes(merge.First), tokenBytes(merge.Second)}
+	}
+	return keys
+}
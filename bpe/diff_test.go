@@ -0,0 +1,67 @@
+package bpe
+
+import "testing"
+
+func TestDiffMergesIdentical(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest newer newest"), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if diff := DiffMerges(tokenizer, tokenizer.Clone()); !diff.Empty() {
+		t.Errorf("Expected no differences from a clone, got %+v", diff)
+	}
+}
+
+func TestDiffMergesDifferentTraining(t *testing.T) {
+	a := New()
+	if err := a.Train([]byte("aaabdaaabac"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// Same text with extra "ab" pairs pulls it ahead of "aa"
+	b := New()
+	if err := b.Train([]byte("aaabdaaabacababab"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	diff := DiffMerges(a, b)
+	if diff.Empty() {
+		t.Fatal("Expected differences")
+	}
+	if len(diff.Changed) == 0 || diff.Changed[0].Rank != 0 {
+		t.Fatalf("Expected the first merge to differ, got %+v", diff.Changed)
+	}
+	if diff.Changed[0].A != a.Merges[0] || diff.Changed[0].B != b.Merges[0] {
+		t.Errorf("Expected each side's own merge at rank 0, got %+v", diff.Changed[0])
+	}
+
+	// Every merge is either shared or listed on its side
+	shared := len(a.Merges) - len(diff.OnlyInA)
+	if shared != len(b.Merges)-len(diff.OnlyInB) {
+		t.Errorf("Shared merge counts disagree: %d from a, %d from b", shared, len(b.Merges)-len(diff.OnlyInB))
+	}
+	if reverse := DiffMerges(b, a); len(reverse.OnlyInA) != len(diff.OnlyInB) || len(reverse.OnlyInB) != len(diff.OnlyInA) {
+		t.Errorf("Expected swapping the arguments to swap the sides, got %+v", reverse)
+	}
+}
+
+func TestDiffMergesComparesBytes(t *testing.T) {
+	// "bc" gets ID 256 in a but 257 in b, so the "abc" merge joins the same
+	// bytes through different IDs
+	a := New()
+	a.AddForcedMerge([]byte("bc"))
+	a.AddForcedMerge([]byte("xy"))
+	a.AddForcedMerge([]byte("abc"))
+	b := New()
+	b.AddForcedMerge([]byte("xy"))
+	b.AddForcedMerge([]byte("bc"))
+	b.AddForcedMerge([]byte("abc"))
+
+	diff := DiffMerges(a, b)
+	if len(diff.OnlyInA) != 0 || len(diff.OnlyInB) != 0 {
+		t.Errorf("Expected the same set of merges, got %+v", diff)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Rank != 0 || diff.Changed[1].Rank != 1 {
+		t.Errorf("Expected ranks 0 and 1 to differ, got %+v", diff.Changed)
+	}
+}
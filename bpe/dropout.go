@@ -0,0 +1,24 @@
+package bpe
+
+import "math/rand/v2"
+
+// EncodeWithDropout encodes text like Encode but skips each merge it is
+// about to apply with probability p (BPE-dropout)
+//
+// A skipped occurrence stays split unless a neighboring merge later changes
+// it, so the same text can come out as different, equally valid token
+// sequences. This is a form of subword regularization for training
+// downstream models; every result decodes back to the original text. With
+// p <= 0 the result equals Encode and rng is never used; with p >= 1 no
+// merges are applied at all. rng is used instead of the global source so
+// results are reproducible for a given seed: two sources seeded alike give
+// identical encodings call for call. rng must not be nil when p > 0, and a
+// *rand.Rand isn't safe for concurrent use, so give each goroutine its own.
+func (t *Tokenizer) EncodeWithDropout(text []byte, p float64, rng *rand.Rand) []int {
+	if p <= 0 {
+		return t.Encode(text)
+	}
+	return t.encode(text, func(int) bool {
+		return rng.Float64() < p
+	})
+}
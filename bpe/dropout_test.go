@@ -0,0 +1,72 @@
+package bpe
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestEncodeWithDropoutZeroMatchesEncode(t *testing.T) {
+	text := generateText(2048)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	expected := tokenizer.Encode(text)
+	for i := 0; i < 3; i++ {
+		if got := tokenizer.EncodeWithDropout(text, 0, rng); !equalTokens(got, expected) {
+			t.Fatalf("EncodeWithDropout with p=0 differs from Encode")
+		}
+	}
+}
+
+func TestEncodeWithDropoutRoundTrip(t *testing.T) {
+	text := generateText(2048)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	full := tokenizer.Encode(text)
+	for i := 0; i < 5; i++ {
+		tokens := tokenizer.EncodeWithDropout(text, 0.5, rng)
+		if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+			t.Fatalf("Dropout encoding failed to round-trip")
+		}
+		if len(tokens) <= len(full) {
+			t.Errorf("Expected dropout to produce more tokens than Encode (%d), got %d", len(full), len(tokens))
+		}
+	}
+
+	// With every merge dropped, the result is byte-level
+	if tokens := tokenizer.EncodeWithDropout(text, 1, rng); len(tokens) != len(text) {
+		t.Errorf("Expected %d byte tokens with p=1, got %d", len(text), len(tokens))
+	}
+}
+
+func TestEncodeWithDropoutSeeded(t *testing.T) {
+	text := generateText(2048)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Same seed, same sequence of encodings
+	a := rand.New(rand.NewPCG(42, 0))
+	b := rand.New(rand.NewPCG(42, 0))
+	for i := 0; i < 3; i++ {
+		if !equalTokens(tokenizer.EncodeWithDropout(text, 0.3, a), tokenizer.EncodeWithDropout(text, 0.3, b)) {
+			t.Fatalf("Encoding %d differs between identically seeded sources", i)
+		}
+	}
+
+	// Different seeds almost surely drop different merges over 2KB of text
+	first := tokenizer.EncodeWithDropout(text, 0.3, rand.New(rand.NewPCG(1, 0)))
+	second := tokenizer.EncodeWithDropout(text, 0.3, rand.New(rand.NewPCG(2, 0)))
+	if equalTokens(first, second) {
+		t.Error("Expected different seeds to give different encodings")
+	}
+}
@@ -0,0 +1,626 @@
+package bpe
+
+import (
+	"maps"
+	"sync"
+)
+
+// rankTable maps each merge pair to its rank (index in Merges)
+// It is derived from Merges and cached on the tokenizer; merges records
+// the slice it was built from so a stale table can be detected. Only
+// addMerge changes a stored table, which like any write to the tokenizer
+// doesn't run concurrently with readers.
+type rankTable struct {
+	merges   []Merge
+	ranks    map[[2]int]int
+	byResult map[int]int // Merge result ID -> rank
+
+	// greedy lets a merge apply after a higher rank has, for EncodeGreedy
+	greedy bool
+}
+
+// loadRanks returns the cached rank table, rebuilding it if Merges changed
+// Rebuilding stores a fresh immutable table, so concurrent readers never
+// observe a partially built map.
+func (t *Tokenizer) loadRanks() *rankTable {
+	if table := t.ranks.Load(); table != nil && table.matches(t.Merges) {
+		return table
+	}
+
+	table := &rankTable{
+		merges:   t.Merges,
+		ranks:    make(map[[2]int]int, len(t.Merges)),
+		byResult: make(map[int]int, len(t.Merges)),
+	}
+	for rank, merge := range t.Merges {
+		pair := [2]int{merge.First, merge.Second}
+		// Keep the earliest rank if a pair was somehow recorded twice
+		if _, exists := table.ranks[pair]; !exists {
+			table.ranks[pair] = rank
+		}
+		if _, exists := table.byResult[merge.Result]; !exists {
+			table.byResult[merge.Result] = rank
+		}
+	}
+	t.ranks.Store(table)
+	return table
+}
+
+// MergeRanks returns each merge pair (First, Second) mapped to its rank,
+// the pair's index in Merges
+// It is derived from a cached table that is rebuilt when Merges changes;
+// the returned map is a copy and may be modified freely.
+func (t *Tokenizer) MergeRanks() map[[2]int]int {
+	return maps.Clone(t.loadRanks().ranks)
+}
+
+// MergeFor returns the merge whose Result is id, or false if id is a base
+// byte, a special token, or otherwise not produced by a merge
+// The lookup uses the same cached table as MergeRanks, so it is O(1).
+func (t *Tokenizer) MergeFor(id int) (Merge, bool) {
+	table := t.loadRanks()
+	rank, ok := table.byResult[id]
+	if !ok {
+		return Merge{}, false
+	}
+	return table.merges[rank], true
+}
+
+// matches reports whether the table was built from this exact merge slice
+// Only the length and backing array are compared, which is enough for the
+// methods that change Merges: they append through addMerge, which extends
+// a current table and drops a stale one, or assign a new slice. Edits made
+// in place need Reindex.
+func (r *rankTable) matches(merges []Merge) bool {
+	if len(r.merges) != len(merges) {
+		return false
+	}
+	return len(merges) == 0 || &r.merges[0] == &merges[0]
+}
+
+// add extends the table with the last merge of merges, which must be the
+// slice the table was built from plus that one merge
+func (r *rankTable) add(merges []Merge) {
+	rank := len(merges) - 1
+	merge := merges[rank]
+	pair := [2]int{merge.First, merge.Second}
+	if _, exists := r.ranks[pair]; !exists {
+		r.ranks[pair] = rank
+	}
+	if _, exists := r.byResult[merge.Result]; !exists {
+		r.byResult[merge.Result] = rank
+	}
+	r.merges = merges
+}
+
+// encodeScratch holds the working buffers for rankTable.apply
+// Buffers are pooled so repeated encodes don't reallocate them.
+type encodeScratch struct {
+	tokens  []int
+	prev    []int
+	next    []int
+	removed []bool
+	queue   candidateQueue
+}
+
+var scratchPool = sync.Pool{
+	New: func() any { return new(encodeScratch) },
+}
+
+// reset sizes the buffers for n tokens, reusing capacity where possible
+func (s *encodeScratch) reset(n int) {
+	if cap(s.prev) < n {
+		s.prev = make([]int, n)
+		s.next = make([]int, n)
+		s.removed = make([]bool, n)
+	}
+	s.prev = s.prev[:n]
+	s.next = s.next[:n]
+	s.removed = s.removed[:n]
+	clear(s.removed)
+	s.queue = s.queue[:0]
+}
+
+// apply merges tokens in place (reusing the backing array) and returns the result
+//
+// Merges are applied in rank order. Within a rank, occurrences are merged
+// left to right. Once a rank has been applied, a pair of a lower rank that
+// appears later is left alone, matching a sequential pass over Merges.
+//
+// If skip is non-nil, it is called for each merge about to be applied and
+// the occurrence is left unmerged when it returns true.
+func (r *rankTable) apply(tokens []int, skip func(rank int) bool) []int {
+	if len(tokens) < 2 || len(r.ranks) == 0 {
+		return tokens
+	}
+
+	scratch := scratchPool.Get().(*encodeScratch)
+	n := r.merge(tokens, scratch, skip)
+	scratchPool.Put(scratch)
+	return tokens[:n]
+}
+
+// merge does the work of apply using the given scratch buffers and returns
+// the number of tokens left at the front of tokens
+func (r *rankTable) merge(tokens []int, scratch *encodeScratch, skip func(rank int) bool) int {
+	if len(tokens) < 2 || len(r.ranks) == 0 {
+		return len(tokens)
+	}
+
+	// Doubly linked list over token positions; a merged pair keeps the
+	// left position and unlinks the right one
+	scratch.reset(len(tokens))
+	prev, next, removed := scratch.prev, scratch.next, scratch.removed
+	for i := range tokens {
+		prev[i] = i - 1
+		next[i] = i + 1
+	}
+	next[len(tokens)-1] = -1
+
+	queue := scratch.queue
+	for i := 0; i < len(tokens)-1; i++ {
+		if rank, ok := r.ranks[[2]int{tokens[i], tokens[i+1]}]; ok {
+			queue = append(queue, candidate{rank: rank, pos: i, first: tokens[i], second: tokens[i+1]})
+		}
+	}
+	queue.init()
+
+	current := 0
+	push := func(left int) {
+		if left < 0 || next[left] < 0 {
+			return
+		}
+		right := next[left]
+		rank, ok := r.ranks[[2]int{tokens[left], tokens[right]}]
+		if ok && (r.greedy || rank >= current) {
+			queue.push(candidate{rank: rank, pos: left, first: tokens[left], second: tokens[right]})
+		}
+	}
+
+	for len(queue) > 0 {
+		c := queue.pop()
+
+		// Skip entries invalidated by an earlier merge
+		right := next[c.pos]
+		if removed[c.pos] || right < 0 || tokens[c.pos] != c.first || tokens[right] != c.second {
+			continue
+		}
+		if skip != nil && skip(c.rank) {
+			continue
+		}
+
+		current = c.rank
+		tokens[c.pos] = r.merges[c.rank].Result
+		removed[right] = true
+		next[c.pos] = next[right]
+		if next[right] >= 0 {
+			prev[next[right]] = c.pos
+		}
+
+		push(prev[c.pos])
+		push(c.pos)
+	}
+	// Hand the (possibly grown) queue back for reuse
+	scratch.queue = queue
+
+	// Compact the surviving tokens to the front of the slice
+	n := 0
+	for i := 0; i >= 0; i = next[i] {
+		tokens[n] = tokens[i]
+		n++
+	}
+	return n
+}
+
+// EncodeWithOffsets encodes text and also returns, for each token, the
+// [start, end) byte range of text it covers
+// Merges only ever join adjacent spans, so the ranges are contiguous and
+// together cover the whole input. With a Normalizer or SpaceMarker set, the
+// ranges index the normalized text rather than text itself.
+func (t *Tokenizer) EncodeWithOffsets(text []byte) ([]int, [][2]int) {
+	tokens := t.Encode(text)
+	offsets := make([][2]int, len(tokens))
+
+	start := 0
+	for i, id := range tokens {
+		end := start + t.encodedWidth(id)
+		offsets[i] = [2]int{start, end}
+		start = end
+	}
+
+	return tokens, offsets
+}
+
+// encodedWidth returns how many bytes of (normalized) input an encoded
+// token covers
+func (t *Tokenizer) encodedWidth(id int) int {
+	if t.alphabet != nil && (id == t.alphabet.unknown || (t.OutOfAlphabet == ReplaceByte && id == t.ReplacementToken && t.IsSpecial(id))) {
+		// The unknown or a special replacement token stands in for one
+		// out-of-alphabet byte
+		return 1
+	}
+	return len(t.Vocabulary[id])
+}
+
+// EncodeRestricted encodes text using only the merges whose Result is in
+// allowed, leaving the pieces of any other merge split
+// Every token in the output is a base byte or an allowed ID, and it still
+// decodes to text. An allowed merge whose inputs include a disallowed token
+// can never apply, since those inputs never form. A nil or empty allowed
+// gives byte-level tokens.
+func (t *Tokenizer) EncodeRestricted(text []byte, allowed map[int]bool) []int {
+	merges := t.Merges
+	return t.encode(text, func(rank int) bool {
+		return !allowed[merges[rank].Result]
+	})
+}
+
+// EncodeAppend encodes text and appends the tokens to dst, returning the
+// extended slice like append
+// Merging happens in place in dst's backing array, so a caller that reuses
+// a buffer with enough capacity (dst[:0]) encodes without allocating when
+// no Pretokenizer or Normalizer is set. The tokens are the same as Encode's.
+func (t *Tokenizer) EncodeAppend(dst []int, text []byte) []int {
+	return t.appendEncoded(dst, t.loadRanks(), text, nil)
+}
+
+// EncodeGreedy encodes text by repeatedly applying the lowest-rank merge
+// that applies anywhere in the sequence, the textbook form of BPE encoding
+//
+// Encode instead makes one pass over Merges: once a merge has been applied,
+// an earlier merge never runs again, even if a later merge creates a pair
+// it would have joined. For a tokenizer built by Train the two always agree,
+// because every merge only uses tokens from merges before it, so no later
+// merge can produce an earlier pair. They can differ for imported or
+// hand-edited merge lists where a merge refers to a token that a later
+// merge produces: EncodeGreedy still applies it, Encode does not. Both
+// results decode to the same text.
+func (t *Tokenizer) EncodeGreedy(text []byte) []int {
+	greedy := *t.loadRanks()
+	greedy.greedy = true
+	return t.appendEncoded(make([]int, 0, len(text)), &greedy, text, nil)
+}
+
+// EncodeSegments encodes each segment independently and returns the tokens
+// concatenated, along with the index in tokens where each segment's tokens
+// begin
+// No merge crosses a segment boundary, so segment i's tokens are exactly
+// Encode(segments[i]) and occupy tokens[starts[i]:starts[i+1]] (or to the
+// end, for the last). An empty segment has no tokens and shares its start
+// with the next.
+func (t *Tokenizer) EncodeSegments(segments [][]byte) ([]int, []int) {
+	ranks := t.loadRanks()
+	size := 0
+	for _, segment := range segments {
+		size += len(segment)
+	}
+
+	tokens := make([]int, 0, size)
+	starts := make([]int, len(segments))
+	for i, segment := range segments {
+		starts[i] = len(tokens)
+		tokens = t.appendEncoded(tokens, ranks, segment, nil)
+	}
+	return tokens, starts
+}
+
+// EncodePieces encodes text and also returns each token's bytes, in a
+// slice parallel to the tokens
+// Pieces share storage with Vocabulary and must not be modified. They
+// concatenate back to the input, or to the normalized input when a
+// Normalizer or SpaceMarker is set. With a restricted alphabet, a byte
+// encoded as the unknown token has an empty piece.
+func (t *Tokenizer) EncodePieces(text []byte) ([]int, [][]byte) {
+	tokens := t.Encode(text)
+	pieces := make([][]byte, len(tokens))
+	for i, id := range tokens {
+		pieces[i] = t.Vocabulary[id]
+	}
+	return tokens, pieces
+}
+
+// EncodeString is Encode for a string
+// The string is converted with a plain []byte(s) copy rather than aliased
+// through unsafe, because a Normalizer or Pretokenizer is free to modify
+// the bytes it is given.
+func (t *Tokenizer) EncodeString(s string) []int {
+	return t.Encode([]byte(s))
+}
+
+// EncodeWithMaxMerges encodes text using only the first maxMerges merges,
+// as if the tokenizer had stopped training there
+// It trades compression for less encoding work: the result is a valid
+// encoding that decodes to text, just with more tokens. maxMerges <= 0
+// gives byte-level tokens, and maxMerges >= len(Merges) equals Encode.
+func (t *Tokenizer) EncodeWithMaxMerges(text []byte, maxMerges int) []int {
+	if maxMerges >= len(t.Merges) {
+		return t.Encode(text)
+	}
+	return t.encode(text, func(rank int) bool {
+		return rank >= maxMerges
+	})
+}
+
+// ReEncode updates tokens encoded before the tokenizer was trained further,
+// so they match what Encode gives now
+// Training more merges onto a tokenizer only appends to Merges, so old
+// tokens still decode correctly but miss the newer merges. ReEncode merges
+// onward from the tokens instead of from raw bytes: the merges they already
+// reflect find nothing to join, so only the merges added since do any work.
+// Tokens from EncodeWithMaxMerges, which used a prefix of Merges, are
+// brought fully up to date the same way. Tokens that skipped merges out of
+// order, like EncodeWithDropout's, are not: a merge that was skipped can't
+// be redone once a later merge has used one of its bytes. For "abc" with
+// merges (a, b) then (b, c), dropout can give [a, bc], which ReEncode
+// leaves alone although Encode gives [ab, c]. Use Encode(Decode(tokens)) to
+// start over from the bytes instead.
+//
+// With a Pretokenizer, the tokens' bytes are split into chunks again and no
+// merge crosses a chunk boundary. Special tokens, including the unknown
+// token of a restricted alphabet, and invalid IDs are copied unchanged and
+// nothing merges across them. The tokens must come from this tokenizer;
+// tokens from another vocabulary give meaningless results.
+func (t *Tokenizer) ReEncode(tokens []int) []int {
+	ranks := t.loadRanks()
+	out := make([]int, 0, len(tokens))
+
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			id := tokens[i]
+			if _, special := t.specialTokens[id]; !special && id >= 0 && id < len(t.Vocabulary) && len(t.Vocabulary[id]) > 0 {
+				continue
+			}
+		}
+		out = t.appendReEncoded(out, ranks, tokens[start:i])
+		if i < len(tokens) {
+			out = append(out, tokens[i])
+		}
+		start = i + 1
+	}
+	return out
+}
+
+// appendReEncoded merges a run of ordinary tokens chunk by chunk and
+// appends the result to dst
+func (t *Tokenizer) appendReEncoded(dst []int, ranks *rankTable, tokens []int) []int {
+	mergeOnto := func(dst, group []int) []int {
+		start := len(dst)
+		dst = append(dst, group...)
+		merged := ranks.apply(dst[start:], nil)
+		return dst[:start+len(merged)]
+	}
+	if t.Pretokenizer == nil || len(tokens) < 2 {
+		return mergeOnto(dst, tokens)
+	}
+
+	var text []byte
+	for _, id := range tokens {
+		text = append(text, t.Vocabulary[id]...)
+	}
+	chunkEnds := make(map[int]bool)
+	end := 0
+	for _, chunk := range t.Pretokenizer(text) {
+		end += len(chunk)
+		chunkEnds[end] = true
+	}
+
+	// Cut wherever a chunk ends between two tokens
+	offset, groupStart := 0, 0
+	for i, id := range tokens {
+		offset += len(t.Vocabulary[id])
+		if chunkEnds[offset] || i == len(tokens)-1 {
+			dst = mergeOnto(dst, tokens[groupStart:i+1])
+			groupStart = i + 1
+		}
+	}
+	return dst
+}
+
+// TruncStrategy selects which end of a sequence EncodeTruncated cuts
+type TruncStrategy int
+
+const (
+	// TruncateTail keeps the first maxTokens tokens
+	TruncateTail TruncStrategy = iota
+	// TruncateHead keeps the last maxTokens tokens
+	TruncateHead
+)
+
+// EncodeTruncated encodes text and cuts the result to at most maxTokens
+// tokens, dropping the end (TruncateTail) or the start (TruncateHead)
+// The kept tokens are exactly those of Encode(text). With a Pretokenizer,
+// chunks are encoded from the kept end and encoding stops once maxTokens
+// is reached, so a long input costs no more than the part that is kept;
+// without one the whole text has to be encoded first.
+func (t *Tokenizer) EncodeTruncated(text []byte, maxTokens int, strategy TruncStrategy) []int {
+	if maxTokens <= 0 {
+		return []int{}
+	}
+	if t.Pretokenizer == nil {
+		tokens := t.Encode(text)
+		if len(tokens) <= maxTokens {
+			return tokens
+		}
+		if strategy == TruncateHead {
+			return tokens[len(tokens)-maxTokens:]
+		}
+		return tokens[:maxTokens]
+	}
+
+	ranks := t.loadRanks()
+	chunks := t.Pretokenizer(t.normalize(text))
+	if strategy != TruncateHead {
+		tokens := make([]int, 0, maxTokens)
+		for _, chunk := range chunks {
+			start := len(tokens)
+			tokens = t.appendByteTokens(tokens, chunk)
+			merged := ranks.apply(tokens[start:], nil)
+			tokens = tokens[:start+len(merged)]
+			if len(tokens) >= maxTokens {
+				return tokens[:maxTokens]
+			}
+		}
+		return tokens
+	}
+
+	// Encode chunks from the end until enough tokens are collected, then
+	// put them back in order
+	var pieces [][]int
+	count := 0
+	for i := len(chunks) - 1; i >= 0 && count < maxTokens; i-- {
+		piece := ranks.apply(t.appendByteTokens(nil, chunks[i]), nil)
+		pieces = append(pieces, piece)
+		count += len(piece)
+	}
+	tokens := make([]int, 0, count)
+	for i := len(pieces) - 1; i >= 0; i-- {
+		tokens = append(tokens, pieces[i]...)
+	}
+	return tokens[max(0, len(tokens)-maxTokens):]
+}
+
+// CountTokens returns len(Encode(text)) without building the token slice
+// It runs the same merge logic as Encode on pooled buffers, which makes it
+// cheaper for budget checks where the IDs themselves aren't needed.
+func (t *Tokenizer) CountTokens(text []byte) int {
+	ranks := t.loadRanks()
+	scratch := scratchPool.Get().(*encodeScratch)
+	defer scratchPool.Put(scratch)
+
+	text = t.normalize(text)
+	if t.Pretokenizer == nil {
+		return t.countChunk(ranks, scratch, text)
+	}
+
+	total := 0
+	for _, chunk := range t.Pretokenizer(text) {
+		total += t.countChunk(ranks, scratch, chunk)
+	}
+	return total
+}
+
+// FitsInBudget reports whether text encodes to at most budget tokens, and
+// the number of tokens counted, doing no more encoding than it has to
+// When it returns true the count is exact, the same as CountTokens. Once
+// the count is known to go over budget it stops and returns false with a
+// count that is above budget but may be below the full count. Text too
+// long to fit even if every token were MaxTokenBytes long (not counting
+// bytes that OutOfAlphabet drops) is rejected without encoding;
+// otherwise, with a Pretokenizer, chunks are counted in order and counting
+// stops at the first chunk that goes over. Without one, text that might
+// fit has to be encoded in full.
+func (t *Tokenizer) FitsInBudget(text []byte, budget int) (bool, int) {
+	text = t.normalize(text)
+	// Only bytes that reach the encoding count toward the bound; bytes
+	// outside the alphabet may be dropped
+	kept := len(text)
+	if t.alphabet != nil && t.outOfAlphabetToken() < 0 {
+		for _, b := range text {
+			if t.alphabet.ids[b] < 0 {
+				kept--
+			}
+		}
+	}
+	widest := max(1, t.MaxTokenBytes())
+	if fewest := (kept + widest - 1) / widest; fewest > budget {
+		return false, fewest
+	}
+
+	ranks := t.loadRanks()
+	scratch := scratchPool.Get().(*encodeScratch)
+	defer scratchPool.Put(scratch)
+
+	if t.Pretokenizer == nil {
+		count := t.countChunk(ranks, scratch, text)
+		return count <= budget, count
+	}
+
+	total := 0
+	for _, chunk := range t.Pretokenizer(text) {
+		total += t.countChunk(ranks, scratch, chunk)
+		if total > budget {
+			return false, total
+		}
+	}
+	return true, total
+}
+
+// countChunk returns the number of tokens a normalized, already
+// pretokenized chunk encodes to, using scratch for the work
+func (t *Tokenizer) countChunk(ranks *rankTable, scratch *encodeScratch, chunk []byte) int {
+	scratch.tokens = t.appendByteTokens(scratch.tokens[:0], chunk)
+	return ranks.merge(scratch.tokens, scratch, nil)
+}
+
+// candidate is a mergeable pair (first, second) starting at pos
+// The pair is recorded so stale entries can be detected cheaply on pop.
+type candidate struct {
+	rank   int
+	pos    int
+	first  int
+	second int
+}
+
+// candidateQueue is a min-heap ordered by rank, then by position
+// It is hand-rolled rather than built on container/heap to avoid boxing
+// every candidate in an interface on push and pop.
+type candidateQueue []candidate
+
+func (q candidateQueue) less(i, j int) bool {
+	if q[i].rank != q[j].rank {
+		return q[i].rank < q[j].rank
+	}
+	return q[i].pos < q[j].pos
+}
+
+func (q *candidateQueue) init() {
+	for i := len(*q)/2 - 1; i >= 0; i-- {
+		q.down(i)
+	}
+}
+
+func (q *candidateQueue) push(c candidate) {
+	*q = append(*q, c)
+	q.up(len(*q) - 1)
+}
+
+func (q *candidateQueue) pop() candidate {
+	old := *q
+	top := old[0]
+	last := len(old) - 1
+	old[0] = old[last]
+	*q = old[:last]
+	q.down(0)
+	return top
+}
+
+func (q candidateQueue) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		q[i], q[parent] = q[parent], q[i]
+		i = parent
+	}
+}
+
+func (q candidateQueue) down(i int) {
+	n := len(q)
+	for {
+		smallest := i
+		left, right := 2*i+1, 2*i+2
+		if left < n && q.less(left, smallest) {
+			smallest = left
+		}
+		if right < n && q.less(right, smallest) {
+			smallest = right
+		}
+		if smallest == i {
+			return
+		}
+		q[i], q[smallest] = q[smallest], q[i]
+		i = smallest
+	}
+}
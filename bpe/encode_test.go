@@ -0,0 +1,570 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCountTokensMatchesEncode(t *testing.T) {
+	corpora := [][]byte{
+		[]byte("aaabdaaabac"),
+		[]byte("low lower lowest"),
+		[]byte("ababababab"),
+		[]byte("a"),
+		[]byte(""),
+		generateText(4096),
+	}
+
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		for _, corpus := range corpora {
+			tokenizer := New()
+			tokenizer.Pretokenizer = pretokenizer
+			if err := tokenizer.Train(corpus, 300); err != nil {
+				t.Fatalf("Training failed: %v", err)
+			}
+
+			for _, input := range corpora {
+				expected := len(tokenizer.Encode(input))
+				if got := tokenizer.CountTokens(input); got != expected {
+					t.Errorf("CountTokens(%q) = %d, expected %d", input, got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestCountTokensUntrained(t *testing.T) {
+	tokenizer := New()
+	text := []byte("Hello, World!")
+
+	if got := tokenizer.CountTokens(text); got != len(text) {
+		t.Errorf("Expected %d tokens, got %d", len(text), got)
+	}
+}
+
+func TestFitsInBudget(t *testing.T) {
+	text := generateText(4096)
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(text, 300); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		full := tokenizer.CountTokens(text)
+
+		// Just under and exactly at the budget
+		for _, budget := range []int{full, full + 1} {
+			if fits, count := tokenizer.FitsInBudget(text, budget); !fits || count != full {
+				t.Errorf("Budget %d: expected (true, %d), got (%v, %d)", budget, full, fits, count)
+			}
+		}
+
+		// Just over the budget
+		if fits, count := tokenizer.FitsInBudget(text, full-1); fits || count <= full-1 || count > full {
+			t.Errorf("Budget %d: expected false with a count in (%d, %d], got (%v, %d)", full-1, full-1, full, fits, count)
+		}
+	}
+}
+
+func TestFitsInBudgetStopsEarly(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	text := generateText(64 * 1024)
+	if err := tokenizer.Train(text[:4096], 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Counting stops within a chunk of the budget rather than at the end
+	budget := tokenizer.CountTokens(text) / 2
+	fits, count := tokenizer.FitsInBudget(text, budget)
+	if fits || count <= budget || count > budget+50 {
+		t.Errorf("Expected to stop just past %d tokens, got (%v, %d)", budget, fits, count)
+	}
+
+	// Text longer than budget tokens of the longest length is rejected up front
+	untrained := New()
+	if fits, count := untrained.FitsInBudget([]byte("Hello, World!"), 5); fits || count != 13 {
+		t.Errorf("Expected (false, 13), got (%v, %d)", fits, count)
+	}
+}
+
+func TestFitsInBudgetDroppedBytes(t *testing.T) {
+	tokenizer := NewWithAlphabet([]byte("ab"))
+	tokenizer.OutOfAlphabet = DropByte
+	text := []byte("xxxxxxxxxxa")
+	if count := tokenizer.CountTokens(text); count != 1 {
+		t.Fatalf("Expected the dropped bytes to leave 1 token, got %d", count)
+	}
+
+	// The up-front bound must not count bytes that are dropped
+	if fits, count := tokenizer.FitsInBudget(text, 3); !fits || count != 1 {
+		t.Errorf("Expected (true, 1), got (%v, %d)", fits, count)
+	}
+	if fits, _ := tokenizer.FitsInBudget(text, 0); fits {
+		t.Error("Expected a budget of 0 to be exceeded")
+	}
+}
+
+func TestMergeRanks(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest newer newest"), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	ranks := tokenizer.MergeRanks()
+	if len(ranks) != len(tokenizer.Merges) {
+		t.Fatalf("Expected %d ranks, got %d", len(tokenizer.Merges), len(ranks))
+	}
+
+	// Ranks are dense 0..n-1 and match each merge's position
+	seen := make([]bool, len(tokenizer.Merges))
+	for pair, rank := range ranks {
+		if rank < 0 || rank >= len(seen) || seen[rank] {
+			t.Fatalf("Rank %d for %v is out of range or duplicated", rank, pair)
+		}
+		seen[rank] = true
+		merge := tokenizer.Merges[rank]
+		if merge.First != pair[0] || merge.Second != pair[1] {
+			t.Errorf("Rank %d maps to %v but Merges[%d] is %+v", rank, pair, rank, merge)
+		}
+	}
+
+	// The returned map is a copy, so mutating it doesn't affect encoding
+	for pair := range ranks {
+		delete(ranks, pair)
+	}
+	if len(tokenizer.MergeRanks()) != len(tokenizer.Merges) {
+		t.Error("Mutating the returned map changed the cached ranks")
+	}
+}
+
+func TestRankCacheTracksEdits(t *testing.T) {
+	tokenizer := New()
+	for _, pair := range [][2]int{{'a', 'b'}, {'c', 'd'}} {
+		if _, err := tokenizer.AddMerge(pair[0], pair[1]); err != nil {
+			t.Fatalf("AddMerge failed: %v", err)
+		}
+	}
+	if got := tokenizer.EncodeString("abcd"); !equalTokens(got, []int{256, 257}) {
+		t.Fatalf("Expected [256 257], got %v", got)
+	}
+
+	// Truncating and growing back over the same array keeps the slice's
+	// length and start, but the merges are different; appending through a
+	// method is enough for Encode to notice
+	tokenizer.Merges = tokenizer.Merges[:1]
+	tokenizer.Vocabulary = tokenizer.Vocabulary[:257]
+	tokenizer.VocabSize = 257
+	if _, err := tokenizer.AddMerge('c', 'e'); err != nil {
+		t.Fatalf("AddMerge failed: %v", err)
+	}
+	if got := tokenizer.EncodeString("abcdce"); !equalTokens(got, []int{256, 'c', 'd', 257}) {
+		t.Errorf("Expected [256 99 100 257] after re-appending, got %v", got)
+	}
+
+	// Editing a merge in place needs Reindex
+	tokenizer.Merges[0] = Merge{First: 'x', Second: 'y', Result: 256}
+	tokenizer.Vocabulary[256] = []byte("xy")
+	tokenizer.Reindex()
+	if got := tokenizer.EncodeString("xyab"); !equalTokens(got, []int{256, 'a', 'b'}) {
+		t.Errorf("Expected [256 97 98] after Reindex, got %v", got)
+	}
+	if id, ok := tokenizer.TokenForBytes([]byte("xy")); !ok || id != 256 {
+		t.Errorf("Expected TokenForBytes to find the edited token, got (%d, %v)", id, ok)
+	}
+}
+
+func TestMergeRanksTracksNewMerges(t *testing.T) {
+	tokenizer := New()
+	if len(tokenizer.MergeRanks()) != 0 {
+		t.Errorf("Expected no ranks before training")
+	}
+
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.MergeRanks()) != 4 {
+		t.Errorf("Expected 4 ranks after training, got %d", len(tokenizer.MergeRanks()))
+	}
+
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 262); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.MergeRanks()) != 6 {
+		t.Errorf("Expected 6 ranks after continued training, got %d", len(tokenizer.MergeRanks()))
+	}
+}
+
+func TestMergeFor(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if merge, ok := tokenizer.MergeFor(256); !ok || merge != tokenizer.Merges[0] {
+		t.Errorf("Expected MergeFor(256) = %+v, got %+v (found=%v)", tokenizer.Merges[0], merge, ok)
+	}
+	for _, merge := range tokenizer.Merges {
+		if got, ok := tokenizer.MergeFor(merge.Result); !ok || got != merge {
+			t.Errorf("Expected MergeFor(%d) = %+v, got %+v (found=%v)", merge.Result, merge, got, ok)
+		}
+	}
+	for _, id := range []int{97, 260, -1} {
+		if merge, ok := tokenizer.MergeFor(id); ok {
+			t.Errorf("Expected no merge for %d, got %+v", id, merge)
+		}
+	}
+
+	// The index follows continued training
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 261); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if _, ok := tokenizer.MergeFor(260); !ok {
+		t.Error("Expected a merge for the newly learned token 260")
+	}
+}
+
+func TestEncodeWithOffsets(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	text := []byte("lowest")
+	tokens, offsets := tokenizer.EncodeWithOffsets(text)
+
+	if !equalTokens(tokens, tokenizer.Encode(text)) {
+		t.Errorf("Tokens differ from Encode: %v vs %v", tokens, tokenizer.Encode(text))
+	}
+	if len(offsets) != len(tokens) {
+		t.Fatalf("Expected %d offsets, got %d", len(tokens), len(offsets))
+	}
+
+	// Offsets are contiguous, cover the input, and slice out each token's bytes
+	reconstructed := []byte{}
+	expectedStart := 0
+	for i, span := range offsets {
+		if span[0] != expectedStart {
+			t.Errorf("Offset %d starts at %d, expected %d", i, span[0], expectedStart)
+		}
+		piece := text[span[0]:span[1]]
+		if string(piece) != string(tokenizer.Vocabulary[tokens[i]]) {
+			t.Errorf("Offset %d covers %q but token is %q", i, piece, tokenizer.Vocabulary[tokens[i]])
+		}
+		reconstructed = append(reconstructed, piece...)
+		expectedStart = span[1]
+	}
+
+	if string(reconstructed) != string(text) {
+		t.Errorf("Slicing by offsets gave %q, expected %q", reconstructed, text)
+	}
+}
+
+func TestEncodeWithOffsetsEmpty(t *testing.T) {
+	tokens, offsets := New().EncodeWithOffsets(nil)
+	if len(tokens) != 0 || len(offsets) != 0 {
+		t.Errorf("Expected no tokens or offsets, got %v and %v", tokens, offsets)
+	}
+}
+
+func TestEncodeWithMaxMerges(t *testing.T) {
+	text := generateText(2048)
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train(text, 350); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if got := tokenizer.EncodeWithMaxMerges(text, len(tokenizer.Merges)); !equalTokens(got, tokenizer.Encode(text)) {
+		t.Error("EncodeWithMaxMerges with every merge differs from Encode")
+	}
+
+	for _, limit := range []int{0, 1, 10, 50} {
+		tokens := tokenizer.EncodeWithMaxMerges(text, limit)
+		if decoded := tokenizer.Decode(tokens); string(decoded) != string(text) {
+			t.Fatalf("maxMerges=%d: failed to round-trip", limit)
+		}
+
+		// Same result as a tokenizer that only learned the first limit merges
+		truncated := New()
+		truncated.Pretokenizer = GPT2Pretokenizer
+		truncated.Merges = tokenizer.Merges[:limit]
+		if expected := truncated.Encode(text); !equalTokens(tokens, expected) {
+			t.Errorf("maxMerges=%d: expected %d tokens, got %d", limit, len(expected), len(tokens))
+		}
+	}
+}
+
+func TestReEncode(t *testing.T) {
+	text := generateVariedText(16 * 1024)
+	other := []byte("the newest tokens, again and again")
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(text, 300); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		sep := tokenizer.AddSpecialToken("<|sep|>")
+		old := append(tokenizer.Encode(text), sep)
+		old = append(old, tokenizer.Encode(other)...)
+
+		// Extend the vocabulary, then catch the old tokens up
+		if err := tokenizer.Train(text, 600); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		expected := append(tokenizer.Encode(text), sep)
+		expected = append(expected, tokenizer.Encode(other)...)
+
+		got := tokenizer.ReEncode(old)
+		if !equalTokens(got, expected) {
+			t.Errorf("Expected %d tokens matching Encode, got %d", len(expected), len(got))
+		}
+		if len(got) >= len(old) {
+			t.Errorf("Expected the new merges to shorten %d tokens, got %d", len(old), len(got))
+		}
+
+		// Partially merged tokens are brought fully up to date too
+		if got := tokenizer.ReEncode(tokenizer.EncodeWithMaxMerges(other, 20)); !equalTokens(got, tokenizer.Encode(other)) {
+			t.Errorf("Expected %v, got %v", tokenizer.Encode(other), got)
+		}
+	}
+}
+
+func TestReEncodeLeavesOutOfOrderMerges(t *testing.T) {
+	tokenizer := New()
+	ab, err := tokenizer.AddMerge('a', 'b')
+	if err != nil {
+		t.Fatalf("AddMerge failed: %v", err)
+	}
+	bc, err := tokenizer.AddMerge('b', 'c')
+	if err != nil {
+		t.Fatalf("AddMerge failed: %v", err)
+	}
+
+	// Dropout can skip (a, b) and then apply (b, c), which Encode never does
+	text := []byte("abc")
+	dropped := []int{'a', bc}
+	if !bytes.Equal(tokenizer.Decode(dropped), text) {
+		t.Fatalf("Expected %v to decode to %q", dropped, text)
+	}
+	if got := tokenizer.ReEncode(dropped); !equalTokens(got, dropped) {
+		t.Errorf("Expected ReEncode to leave %v alone, got %v", dropped, got)
+	}
+	expected := []int{ab, 'c'}
+	if got := tokenizer.Encode(tokenizer.Decode(dropped)); !equalTokens(got, expected) {
+		t.Errorf("Expected re-encoding from bytes to give %v, got %v", expected, got)
+	}
+}
+
+func TestEncodeTruncated(t *testing.T) {
+	text := generateText(4096)
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(text, 350); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		full := tokenizer.Encode(text)
+
+		for _, maxTokens := range []int{1, 10, 100, len(full) - 1} {
+			if got := tokenizer.EncodeTruncated(text, maxTokens, TruncateTail); !equalTokens(got, full[:maxTokens]) {
+				t.Errorf("TruncateTail to %d tokens (pretokenizer=%v): expected %v, got %v",
+					maxTokens, pretokenizer != nil, full[:maxTokens], got)
+			}
+			if got := tokenizer.EncodeTruncated(text, maxTokens, TruncateHead); !equalTokens(got, full[len(full)-maxTokens:]) {
+				t.Errorf("TruncateHead to %d tokens (pretokenizer=%v): expected %v, got %v",
+					maxTokens, pretokenizer != nil, full[len(full)-maxTokens:], got)
+			}
+		}
+
+		// No truncation needed: both strategies return the full encoding
+		for _, strategy := range []TruncStrategy{TruncateTail, TruncateHead} {
+			for _, maxTokens := range []int{len(full), len(full) + 100} {
+				if got := tokenizer.EncodeTruncated(text, maxTokens, strategy); !equalTokens(got, full) {
+					t.Errorf("Strategy %d with room for %d tokens: expected the full encoding, got %d tokens",
+						strategy, maxTokens, len(got))
+				}
+			}
+			if got := tokenizer.EncodeTruncated(text, 0, strategy); len(got) != 0 {
+				t.Errorf("Expected no tokens for maxTokens 0, got %v", got)
+			}
+		}
+	}
+}
+
+func TestEncodeAppend(t *testing.T) {
+	text := generateText(2048)
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(text, 350); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		prefix := []int{1, 2, 3}
+		got := tokenizer.EncodeAppend(prefix, text)
+		expected := append([]int{1, 2, 3}, tokenizer.Encode(text)...)
+		if !equalTokens(got, expected) {
+			t.Errorf("EncodeAppend (pretokenizer=%v) differs from appending Encode", pretokenizer != nil)
+		}
+		if got := tokenizer.EncodeAppend(nil, nil); len(got) != 0 {
+			t.Errorf("Expected no tokens for empty text, got %v", got)
+		}
+	}
+}
+
+func TestEncodeAppendReusesBuffer(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable under the race detector")
+	}
+	tokenizer := New()
+	text := generateText(2048)
+	if err := tokenizer.Train(text, 350); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	buf := make([]int, 0, len(text))
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = tokenizer.EncodeAppend(buf[:0], text)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected EncodeAppend into a large enough buffer not to allocate, got %v allocations", allocs)
+	}
+}
+
+func TestEncodeGreedy(t *testing.T) {
+	// Rank 0 joins "a" with the token rank 1 creates, which a single pass
+	// over the merges has already gone past
+	tokenizer := New()
+	tokenizer.Vocabulary = append(tokenizer.Vocabulary, []byte("abc"), []byte("bc"))
+	tokenizer.VocabSize = len(tokenizer.Vocabulary)
+	tokenizer.Merges = []Merge{{First: 'a', Second: 257, Result: 256}, {First: 'b', Second: 'c', Result: 257}}
+	tokenizer.rebuildIndex()
+
+	text := []byte("abcxabc")
+	sequential := tokenizer.Encode(text)
+	greedy := tokenizer.EncodeGreedy(text)
+	if expected := []int{'a', 257, 'x', 'a', 257}; !equalTokens(sequential, expected) {
+		t.Errorf("Expected Encode to give %v, got %v", expected, sequential)
+	}
+	if expected := []int{256, 'x', 256}; !equalTokens(greedy, expected) {
+		t.Errorf("Expected EncodeGreedy to give %v, got %v", expected, greedy)
+	}
+	for _, tokens := range [][]int{sequential, greedy} {
+		if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+			t.Errorf("Expected %q, got %q", text, decoded)
+		}
+	}
+}
+
+func TestEncodeGreedyMatchesEncodeWhenTrained(t *testing.T) {
+	text := generateVariedText(16 * 1024)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 600); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !equalTokens(tokenizer.EncodeGreedy(text), tokenizer.Encode(text)) {
+		t.Error("Expected EncodeGreedy to match Encode for a trained tokenizer")
+	}
+}
+
+func TestEncodeRestricted(t *testing.T) {
+	text := generateVariedText(8 * 1024)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 500); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if got := tokenizer.EncodeRestricted(text, map[int]bool{}); !equalTokens(got, New().Encode(text)) {
+		t.Error("Expected an empty allowed set to give byte-level tokens")
+	}
+
+	// Allow every other merge
+	allowed := make(map[int]bool)
+	for i, merge := range tokenizer.Merges {
+		if i%2 == 0 {
+			allowed[merge.Result] = true
+		}
+	}
+	tokens := tokenizer.EncodeRestricted(text, allowed)
+	for _, id := range tokens {
+		if id >= 256 && !allowed[id] {
+			t.Fatalf("Token %d is not in the allowed set", id)
+		}
+	}
+	if len(tokens) <= len(tokenizer.Encode(text)) || len(tokens) >= len(text) {
+		t.Errorf("Expected a partial encoding, got %d tokens for %d bytes", len(tokens), len(text))
+	}
+	if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+		t.Error("Restricted encoding doesn't decode to the original text")
+	}
+
+	everything := make(map[int]bool)
+	for _, merge := range tokenizer.Merges {
+		everything[merge.Result] = true
+	}
+	if !equalTokens(tokenizer.EncodeRestricted(text, everything), tokenizer.Encode(text)) {
+		t.Error("Expected allowing every merge to match Encode")
+	}
+}
+
+func TestEncodeSegments(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("abababab"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Joined, the "b" + "a" at the boundary would merge into the "abab" token
+	segments := [][]byte{[]byte("aba"), []byte("bab"), []byte(""), []byte("ab")}
+	tokens, starts := tokenizer.EncodeSegments(segments)
+
+	var expected []int
+	var expectedStarts []int
+	for _, segment := range segments {
+		expectedStarts = append(expectedStarts, len(expected))
+		expected = append(expected, tokenizer.Encode(segment)...)
+	}
+	if !equalTokens(tokens, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens)
+	}
+	if !equalTokens(starts, expectedStarts) {
+		t.Errorf("Expected starts %v, got %v", expectedStarts, starts)
+	}
+	if joined := tokenizer.Encode(bytes.Join(segments, nil)); equalTokens(joined, tokens) {
+		t.Error("Expected encoding the joined text to merge across the boundary")
+	}
+	for i, segment := range segments {
+		end := len(tokens)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if decoded := tokenizer.Decode(tokens[starts[i]:end]); !bytes.Equal(decoded, segment) {
+			t.Errorf("Segment %d: expected %q, got %q", i, segment, decoded)
+		}
+	}
+}
+
+func TestEncodePieces(t *testing.T) {
+	text := []byte("the lowest newest widest, naïve")
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train(text, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens, pieces := tokenizer.EncodePieces(text)
+	if !equalTokens(tokens, tokenizer.Encode(text)) {
+		t.Error("Expected the same tokens as Encode")
+	}
+	if len(pieces) != len(tokens) {
+		t.Fatalf("Expected %d pieces, got %d", len(tokens), len(pieces))
+	}
+	for i, id := range tokens {
+		if !bytes.Equal(pieces[i], tokenizer.Vocabulary[id]) {
+			t.Errorf("Piece %d: expected %q, got %q", i, tokenizer.Vocabulary[id], pieces[i])
+		}
+	}
+	if joined := bytes.Join(pieces, nil); !bytes.Equal(joined, text) {
+		t.Errorf("Expected pieces to concatenate to %q, got %q", text, joined)
+	}
+}
@@ -0,0 +1,67 @@
+package bpe
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileBufferSize is how much of a file EncodeFile reads at a time
+const fileBufferSize = 64 * 1024
+
+// EncodeFile encodes the contents of the file at path
+// The file is read in pieces through a stream Encoder, so with a
+// Pretokenizer set only the chunk being read is held in memory, not the
+// whole file. Without one, any merge may span the whole input and the text
+// is held until the end, as with Encode. The tokens are the same as
+// Encode's on the file's contents.
+func (t *Tokenizer) EncodeFile(path string) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("encoding file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := t.NewStreamEncoder()
+	tokens := []int{}
+	buf := make([]byte, fileBufferSize)
+	for {
+		n, err := f.Read(buf)
+		tokens = append(tokens, encoder.Write(buf[:n])...)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("encoding file: %w", err)
+		}
+	}
+	return append(tokens, encoder.Flush()...), nil
+}
+
+// DecodeToFile decodes tokens into the file at path, creating it or
+// truncating an existing file
+// Tokens are written through a buffer as they are decoded rather than
+// collected first (see DecodeTo), and invalid IDs are handled exactly like
+// Decode.
+func (t *Tokenizer) DecodeToFile(path string, tokens []int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("decoding to file: %w", err)
+	}
+
+	bw := bufio.NewWriter(f)
+	if _, err := t.DecodeTo(bw, tokens); err != nil {
+		f.Close()
+		return fmt.Errorf("decoding to file: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("decoding to file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("decoding to file: %w", err)
+	}
+	return nil
+}
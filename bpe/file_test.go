@@ -0,0 +1,93 @@
+package bpe
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeFileDecodeToFile(t *testing.T) {
+	// Large enough to be read in several pieces, with multi-byte characters
+	// cut at the piece boundaries
+	content := bytes.Repeat([]byte("café naïve déjà vu, the quick brown fox\n"), 4000)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, content, 0o644); err != nil {
+		t.Fatalf("Writing input failed: %v", err)
+	}
+
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(content[:8192], 400); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		tokens, err := tokenizer.EncodeFile(input)
+		if err != nil {
+			t.Fatalf("EncodeFile failed: %v", err)
+		}
+		if !equalTokens(tokens, tokenizer.Encode(content)) {
+			t.Error("Expected EncodeFile to match Encode")
+		}
+
+		output := filepath.Join(dir, "output.txt")
+		if err := tokenizer.DecodeToFile(output, tokens); err != nil {
+			t.Fatalf("DecodeToFile failed: %v", err)
+		}
+		decoded, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatalf("Reading output failed: %v", err)
+		}
+		if !bytes.Equal(decoded, content) {
+			t.Error("Decoded file doesn't match original")
+		}
+	}
+}
+
+func TestEncodeFileContractionAcrossReads(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train(bytes.Repeat([]byte("we'll see what they're doing "), 50), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if _, ok := tokenizer.TokenForBytes([]byte("'ll")); !ok {
+		t.Fatal("Expected \"'ll\" to be learned")
+	}
+
+	// Put the "'" and "l" of "'ll" either side of each read boundary
+	for _, offset := range []int{1, 2} {
+		filler := bytes.Repeat([]byte("x"), fileBufferSize-len(" we")-offset)
+		content := append(filler, " we'll see what they're doing"...)
+		if i := bytes.Index(content, []byte("'ll")); i+offset != fileBufferSize {
+			t.Fatalf("Expected \"'ll\" to straddle the read boundary, found it at %d", i)
+		}
+		path := filepath.Join(t.TempDir(), "input.txt")
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatalf("Writing input failed: %v", err)
+		}
+
+		tokens, err := tokenizer.EncodeFile(path)
+		if err != nil {
+			t.Fatalf("EncodeFile failed: %v", err)
+		}
+		if expected := tokenizer.Encode(content); !equalTokens(tokens, expected) {
+			t.Errorf("Offset %d: expected EncodeFile to match Encode", offset)
+		}
+	}
+}
+
+func TestEncodeFileErrors(t *testing.T) {
+	tokenizer := New()
+	dir := t.TempDir()
+
+	if _, err := tokenizer.EncodeFile(filepath.Join(dir, "missing.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not-exist error, got %v", err)
+	}
+	if err := tokenizer.DecodeToFile(filepath.Join(dir, "missing", "out.txt"), []int{'a'}); err == nil {
+		t.Error("Expected an error writing into a missing directory")
+	}
+}
@@ -0,0 +1,122 @@
+package bpe
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+// DecodeChecked decodes tokens after checking that fingerprint, recorded
+// from Fingerprint when the tokens were encoded, matches this tokenizer
+// Store the fingerprint next to encoded data to catch tokens from one
+// tokenizer being decoded with another, which otherwise yields plausible
+// but wrong text. Invalid token IDs are an error too, as in DecodeStrict.
+// The costly part of the fingerprint is cached (see Fingerprint), so after
+// the first call the check adds little to decoding.
+func (t *Tokenizer) DecodeChecked(tokens []int, fingerprint string) ([]byte, error) {
+	if actual := t.Fingerprint(); fingerprint != actual {
+		return nil, fmt.Errorf("tokens were encoded by tokenizer %.12s, not this one (%.12s)", fingerprint, actual)
+	}
+	return t.DecodeStrict(tokens)
+}
+
+// fingerprintCache holds the hash of the vocabulary, merges and special
+// tokens, which costs time proportional to the vocabulary to compute
+// It is cached on the tokenizer; vocabulary and merges record the slices
+// it was computed from so a stale hash can be detected.
+type fingerprintCache struct {
+	vocabulary [][]byte
+	merges     []Merge
+	sum        []byte
+}
+
+// matches reports whether the cache was computed from these slices
+func (c *fingerprintCache) matches(vocabulary [][]byte, merges []Merge) bool {
+	if len(c.vocabulary) != len(vocabulary) || len(c.merges) != len(merges) {
+		return false
+	}
+	return (len(vocabulary) == 0 || &c.vocabulary[0] == &vocabulary[0]) &&
+		(len(merges) == 0 || &c.merges[0] == &merges[0])
+}
+
+// Fingerprint returns a hex-encoded SHA-256 hash of everything that
+// determines how the tokenizer encodes and decodes: the vocabulary in ID
+// order, the merges in order, the special tokens, and the settings that
+// change Encode or Decode output (SpaceMarker, OutOfAlphabet,
+// ReplacementToken, UnknownTokenBytes and RenderSpecialTokens)
+// Two tokenizers with the same fingerprint produce the same tokens, so it
+// can check that separate processes loaded the same tokenizer. Pretokenizer
+// and Normalizer are functions and aren't covered. The hash of the
+// vocabulary and merges is cached, like the rank table Encode uses, so call
+// Reindex after editing their entries in place.
+func (t *Tokenizer) Fingerprint() string {
+	cache := t.fingerprint.Load()
+	if cache == nil || !cache.matches(t.Vocabulary, t.Merges) {
+		cache = &fingerprintCache{
+			vocabulary: t.Vocabulary,
+			merges:     t.Merges,
+			sum:        t.contentSum(),
+		}
+		t.fingerprint.Store(cache)
+	}
+
+	h := sha256.New()
+	h.Write(cache.sum)
+	writeHashInt(h, t.VocabSize)
+	writeHashInt(h, int(t.SpaceMarker))
+	writeHashInt(h, int(t.OutOfAlphabet))
+	writeHashInt(h, t.ReplacementToken)
+	writeHashInt(h, len(t.UnknownTokenBytes))
+	h.Write(t.UnknownTokenBytes)
+	if t.RenderSpecialTokens {
+		writeHashInt(h, 1)
+	} else {
+		writeHashInt(h, 0)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentSum hashes the vocabulary, merges and special tokens for
+// Fingerprint
+func (t *Tokenizer) contentSum() []byte {
+	h := sha256.New()
+
+	writeHashInt(h, len(t.Vocabulary))
+	for _, tokenBytes := range t.Vocabulary {
+		writeHashInt(h, len(tokenBytes))
+		h.Write(tokenBytes)
+	}
+
+	writeHashInt(h, len(t.Merges))
+	for _, merge := range t.Merges {
+		writeHashInt(h, merge.First)
+		writeHashInt(h, merge.Second)
+		writeHashInt(h, merge.Result)
+	}
+
+	ids := make([]int, 0, len(t.specialTokens))
+	for id := range t.specialTokens {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	writeHashInt(h, len(ids))
+	for _, id := range ids {
+		writeHashInt(h, id)
+		writeHashInt(h, len(t.specialTokens[id]))
+		h.Write([]byte(t.specialTokens[id]))
+	}
+
+	return h.Sum(nil)
+}
+
+// writeHashInt feeds v to h as a fixed-width integer, so adjacent values
+// can't run together
+func writeHashInt(h hash.Hash, v int) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	h.Write(buf[:])
+}
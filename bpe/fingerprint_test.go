@@ -0,0 +1,154 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	text := []byte("low lower lowest newer newest")
+	train := func(target int) *Tokenizer {
+		tokenizer := New()
+		if err := tokenizer.Train(text, target); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		return tokenizer
+	}
+
+	first, second := train(270), train(270)
+	if first.Fingerprint() != second.Fingerprint() {
+		t.Error("Expected identically trained tokenizers to share a fingerprint")
+	}
+	if len(first.Fingerprint()) != 64 {
+		t.Errorf("Expected a 64-character hex digest, got %q", first.Fingerprint())
+	}
+
+	// A save/load round-trip keeps the fingerprint
+	var buf bytes.Buffer
+	if err := first.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Fingerprint() != first.Fingerprint() {
+		t.Error("Expected a loaded tokenizer to keep its fingerprint")
+	}
+
+	// Near-identical tokenizers all differ
+	fingerprints := map[string]string{first.Fingerprint(): "original"}
+	variants := map[string]*Tokenizer{
+		"one more merge": train(271),
+	}
+	swapped := first.Clone()
+	last := &swapped.Merges[len(swapped.Merges)-1]
+	last.First, last.Second = last.Second, last.First
+	variants["swapped pair"] = swapped
+	special := first.Clone()
+	special.AddSpecialToken("<eos>")
+	variants["special token"] = special
+
+	// Settings that change Encode or Decode output count too
+	marker := first.Clone()
+	marker.SpaceMarker = SentencePieceMarker
+	variants["space marker"] = marker
+	dropping := first.Clone()
+	dropping.OutOfAlphabet = DropByte
+	variants["out-of-alphabet policy"] = dropping
+	replacing := first.Clone()
+	replacing.OutOfAlphabet = ReplaceByte
+	replacing.ReplacementToken = 'a'
+	variants["replacement token"] = replacing
+	unknown := first.Clone()
+	unknown.UnknownTokenBytes = []byte("?")
+	variants["unknown token bytes"] = unknown
+	rendered := first.Clone()
+	rendered.RenderSpecialTokens = true
+	variants["rendered special tokens"] = rendered
+
+	for name, tokenizer := range variants {
+		fingerprint := tokenizer.Fingerprint()
+		if other, exists := fingerprints[fingerprint]; exists {
+			t.Errorf("%s has the same fingerprint as %s", name, other)
+		}
+		fingerprints[fingerprint] = name
+	}
+}
+
+func TestFingerprintTracksEdits(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest newer newest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// fresh hashes a clone, which has nothing cached
+	fresh := func() string { return tokenizer.Clone().Fingerprint() }
+	check := func(step string) {
+		t.Helper()
+		if got, expected := tokenizer.Fingerprint(), fresh(); got != expected {
+			t.Errorf("%s: cached fingerprint %.12s, expected %.12s", step, got, expected)
+		}
+	}
+	check("after training")
+
+	seen := map[string]bool{tokenizer.Fingerprint(): true}
+	changed := func(step string) {
+		t.Helper()
+		check(step)
+		if fingerprint := tokenizer.Fingerprint(); seen[fingerprint] {
+			t.Errorf("%s: expected a new fingerprint", step)
+		} else {
+			seen[fingerprint] = true
+		}
+	}
+
+	tokenizer.AddSpecialToken("<eos>")
+	changed("special token")
+	if _, err := tokenizer.AddMerge('x', 'y'); err != nil {
+		t.Fatalf("AddMerge failed: %v", err)
+	}
+	changed("AddMerge")
+	tokenizer.UnknownTokenBytes = []byte("?")
+	changed("setting")
+
+	// Truncating Merges and growing it back reuses the same arrays, so the
+	// cache sees slices of the length it was computed from
+	tokenizer.Merges = tokenizer.Merges[:len(tokenizer.Merges)-1]
+	tokenizer.Vocabulary = tokenizer.Vocabulary[:len(tokenizer.Vocabulary)-1]
+	tokenizer.VocabSize--
+	if _, err := tokenizer.AddMerge('x', 'z'); err != nil {
+		t.Fatalf("AddMerge failed: %v", err)
+	}
+	changed("merge re-appended")
+
+	// Edits in place need Reindex
+	last := &tokenizer.Merges[len(tokenizer.Merges)-1]
+	last.First, last.Second = last.Second, last.First
+	tokenizer.Reindex()
+	changed("Reindex")
+}
+
+func TestDecodeChecked(t *testing.T) {
+	a := New()
+	if err := a.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	b := New()
+	if err := b.Train([]byte("new newer newest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens := a.EncodeString("lowest")
+	fingerprint := a.Fingerprint()
+
+	decoded, err := a.DecodeChecked(tokens, fingerprint)
+	if err != nil || string(decoded) != "lowest" {
+		t.Errorf("Expected %q, got %q (err=%v)", "lowest", decoded, err)
+	}
+	if _, err := b.DecodeChecked(tokens, fingerprint); err == nil {
+		t.Error("Expected an error decoding with a different tokenizer")
+	}
+	if _, err := a.DecodeChecked([]int{'a', 5000}, fingerprint); err == nil {
+		t.Error("Expected an error for an invalid token ID")
+	}
+}
@@ -0,0 +1,84 @@
+package bpe
+
+import "fmt"
+
+// AddForcedMerge makes Encode turn seq into a single token, regardless of
+// how often seq occurred in training, and returns that token's ID
+//
+// seq is encoded with the existing merges, and the tokens that remain are
+// then joined left to right by new merges appended to Merges. Sub-merges
+// that already exist are reused, and if seq already encodes to one token
+// nothing is added. Because the new merges have the highest ranks, the
+// encoding of other text is unchanged unless it contains seq.
+//
+// Encode only produces the token when seq stands alone or is not absorbed
+// by a neighbor's merge first, and never when a Pretokenizer splits seq
+// into several chunks. It returns -1 if seq is empty or contains a byte
+// outside the tokenizer's alphabet.
+func (t *Tokenizer) AddForcedMerge(seq []byte) int {
+	seq = t.normalize(seq)
+	if len(seq) == 0 {
+		return -1
+	}
+
+	// Checked up front, since OutOfAlphabet may drop or replace such bytes
+	if t.alphabet != nil {
+		for _, b := range seq {
+			if t.alphabet.ids[b] < 0 {
+				return -1
+			}
+		}
+	}
+	tokens := t.appendByteTokens(make([]int, 0, len(seq)), seq)
+	tokens = t.loadRanks().apply(tokens, nil)
+
+	id := tokens[0]
+	for _, next := range tokens[1:] {
+		id = t.addMerge(id, next)
+	}
+	return id
+}
+
+// AddMerge appends the merge rule (first, second) and returns the ID of
+// the token it produces
+//
+// The new token gets the next free ID and the concatenated bytes of first
+// and second, and the merge ranks last, as if training had just learned
+// it. It's meant for building a vocabulary by hand, in tests or from a
+// custom rule set; unlike AddForcedMerge it adds exactly the merge asked
+// for, even if Encode would never form one of its inputs. It returns an
+// error if either ID isn't a token, is a special token, or the pair
+// already has a merge.
+func (t *Tokenizer) AddMerge(first, second int) (int, error) {
+	for _, id := range [2]int{first, second} {
+		if id < 0 || id >= len(t.Vocabulary) || (len(t.Vocabulary[id]) == 0 && !t.IsSpecial(id)) {
+			return -1, fmt.Errorf("token %d is not in the vocabulary", id)
+		}
+		if t.IsSpecial(id) {
+			return -1, fmt.Errorf("token %d is a special token", id)
+		}
+	}
+	if rank, exists := t.loadRanks().ranks[[2]int{first, second}]; exists {
+		return -1, fmt.Errorf("pair (%d, %d) is already merged by merge %d", first, second, rank)
+	}
+	return t.addMerge(first, second), nil
+}
+
+// NewWithSeeds creates a byte-level tokenizer that already knows the given
+// subwords (e.g. common affixes like "ing" and "ed"), so training starts
+// from them instead of from bytes alone
+//
+// Each seed is added in order with AddForcedMerge, which synthesizes the
+// merges that build it; seeds sharing a prefix share those merges, and the
+// intermediate tokens are part of the vocabulary too. Every seed encodes to
+// a single token on its own. Train continues from the seeded merges like it
+// does from any existing ones. It returns an error if a seed is empty.
+func NewWithSeeds(seeds [][]byte) (*Tokenizer, error) {
+	t := New()
+	for i, seed := range seeds {
+		if t.AddForcedMerge(seed) < 0 {
+			return nil, fmt.Errorf("seed %d is empty", i)
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,195 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddForcedMerge(t *testing.T) {
+	tokenizer := New()
+	text := []byte("abcabcabc xyxyxy")
+	if err := tokenizer.Train(text, 262); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	before := tokenizer.Encode(text)
+
+	// "xy" was learned, so forcing "xyz" only needs one more merge
+	xy, ok := tokenizer.TokenForBytes([]byte("xy"))
+	if !ok {
+		t.Fatal("Expected training to learn \"xy\"")
+	}
+	merges := len(tokenizer.Merges)
+	id := tokenizer.AddForcedMerge([]byte("xyz"))
+	if len(tokenizer.Merges) != merges+1 {
+		t.Fatalf("Expected 1 new merge, got %d", len(tokenizer.Merges)-merges)
+	}
+	if last := tokenizer.Merges[len(tokenizer.Merges)-1]; last != (Merge{First: xy, Second: 'z', Result: id}) {
+		t.Errorf("Expected the new merge to build on \"xy\", got %+v", last)
+	}
+
+	if tokens := tokenizer.Encode([]byte("xyz")); len(tokens) != 1 || tokens[0] != id {
+		t.Errorf("Expected [%d] for \"xyz\", got %v", id, tokens)
+	}
+	if !bytes.Equal(tokenizer.Vocabulary[id], []byte("xyz")) {
+		t.Errorf("Expected token %d to be \"xyz\", got %q", id, tokenizer.Vocabulary[id])
+	}
+	if !equalTokens(tokenizer.Encode(text), before) {
+		t.Error("Forcing a merge changed the encoding of text without it")
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Errorf("Validate failed after forced merge: %v", err)
+	}
+
+	// Forcing it again, or a sequence that is already one token, adds nothing
+	merges = len(tokenizer.Merges)
+	if again := tokenizer.AddForcedMerge([]byte("xyz")); again != id {
+		t.Errorf("Expected %d when forcing \"xyz\" again, got %d", id, again)
+	}
+	if single := tokenizer.AddForcedMerge([]byte("q")); single != 'q' {
+		t.Errorf("Expected byte token for a single byte, got %d", single)
+	}
+	if len(tokenizer.Merges) != merges {
+		t.Errorf("Expected no new merges, got %d", len(tokenizer.Merges)-merges)
+	}
+}
+
+func TestAddForcedMergeUntrained(t *testing.T) {
+	tokenizer := New()
+	id := tokenizer.AddForcedMerge([]byte("https://"))
+	if len(tokenizer.Merges) != len("https://")-1 {
+		t.Errorf("Expected %d merges, got %d", len("https://")-1, len(tokenizer.Merges))
+	}
+	if tokens := tokenizer.Encode([]byte("https://")); len(tokens) != 1 || tokens[0] != id {
+		t.Errorf("Expected [%d], got %v", id, tokens)
+	}
+	if id := tokenizer.AddForcedMerge(nil); id != -1 {
+		t.Errorf("Expected -1 for an empty sequence, got %d", id)
+	}
+}
+
+func TestAddForcedMergeOutsideAlphabet(t *testing.T) {
+	// IDs: '?' 0, 'a' 1, 'b' 2, <unk> 3
+	for _, policy := range []OutOfAlphabet{UseUnknownToken, DropByte, ReplaceByte} {
+		tokenizer := NewWithAlphabet([]byte("ab?"))
+		tokenizer.OutOfAlphabet = policy
+		tokenizer.ReplacementToken = 0
+
+		for _, seq := range []string{"\xff\xfe", "a\xffb"} {
+			if id := tokenizer.AddForcedMerge([]byte(seq)); id != -1 {
+				t.Errorf("Policy %d, %q: expected -1, got %d", policy, seq, id)
+			}
+		}
+		if len(tokenizer.Merges) != 0 {
+			t.Errorf("Policy %d: expected no merges, got %+v", policy, tokenizer.Merges)
+		}
+	}
+}
+
+func TestAddMerge(t *testing.T) {
+	tokenizer := New()
+	text := []byte("the other theme")
+	if tokens := tokenizer.Encode(text); len(tokens) != len(text) {
+		t.Fatalf("Expected byte tokens before any merge, got %v", tokens)
+	}
+
+	add := func(first, second int) int {
+		id, err := tokenizer.AddMerge(first, second)
+		if err != nil {
+			t.Fatalf("AddMerge(%d, %d) failed: %v", first, second, err)
+		}
+		return id
+	}
+	th := add('t', 'h')
+	the := add(th, 'e')
+	spaceThe := add(' ', the)
+	if th != 256 || the != 257 || spaceThe != 258 || tokenizer.VocabSize != 259 {
+		t.Fatalf("Expected IDs 256-258 and vocab size 259, got %d, %d, %d and %d", th, the, spaceThe, tokenizer.VocabSize)
+	}
+	if string(tokenizer.Vocabulary[spaceThe]) != " the" {
+		t.Errorf("Expected token %d to be \" the\", got %q", spaceThe, tokenizer.Vocabulary[spaceThe])
+	}
+
+	expected := []int{the, ' ', 'o', the, 'r', spaceThe, 'm', 'e'}
+	tokens := tokenizer.Encode(text)
+	if !equalTokens(tokens, expected) {
+		t.Errorf("Expected %v, got %v", expected, tokens)
+	}
+	if !bytes.Equal(tokenizer.Decode(tokens), text) {
+		t.Error("Decoded text doesn't match original")
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+
+	sep := tokenizer.AddSpecialToken("<sep>")
+	for _, pair := range [][2]int{{th, 'e'}, {-1, 'a'}, {'a', tokenizer.VocabSize}, {sep, 'a'}} {
+		if _, err := tokenizer.AddMerge(pair[0], pair[1]); err == nil {
+			t.Errorf("Expected AddMerge(%d, %d) to fail", pair[0], pair[1])
+		}
+	}
+	if len(tokenizer.Merges) != 3 {
+		t.Errorf("Expected failed calls to add nothing, got %d merges", len(tokenizer.Merges))
+	}
+}
+
+func TestAddMergeExtendsRankCache(t *testing.T) {
+	trained := New()
+	text := generateVariedText(8 * 1024)
+	if err := trained.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Replaying the merges one at a time keeps a single rank table, which
+	// is what makes the duplicate check constant time
+	tokenizer := New()
+	table := tokenizer.loadRanks()
+	for _, merge := range trained.Merges {
+		if _, err := tokenizer.AddMerge(merge.First, merge.Second); err != nil {
+			t.Fatalf("AddMerge(%d, %d) failed: %v", merge.First, merge.Second, err)
+		}
+		if tokenizer.ranks.Load() != table {
+			t.Fatalf("Expected AddMerge to extend the cached rank table at merge %d", len(tokenizer.Merges))
+		}
+	}
+	if !equalTokens(tokenizer.Encode(text), trained.Encode(text)) {
+		t.Error("Encoding with the replayed merges differs from the trained tokenizer")
+	}
+	first := trained.Merges[0]
+	if _, err := tokenizer.AddMerge(first.First, first.Second); err == nil {
+		t.Error("Expected AddMerge to reject a pair the extended table already has")
+	}
+}
+
+func TestNewWithSeeds(t *testing.T) {
+	tokenizer, err := NewWithSeeds([][]byte{[]byte("ing"), []byte("ed")})
+	if err != nil {
+		t.Fatalf("NewWithSeeds failed: %v", err)
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Fatalf("Seeded tokenizer is invalid: %v", err)
+	}
+
+	for _, seed := range []string{"ing", "ed"} {
+		if tokens := tokenizer.EncodeString(seed); len(tokens) != 1 {
+			t.Errorf("Expected %q to encode as one token before training, got %v", seed, tokens)
+		}
+	}
+
+	// Training continues after the seeded merges
+	seeded := len(tokenizer.Merges)
+	if err := tokenizer.Train([]byte("walking talked walked talking"), tokenizer.VocabSize+5); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) != seeded+5 {
+		t.Errorf("Expected 5 more merges, got %d", len(tokenizer.Merges)-seeded)
+	}
+	for _, seed := range []string{"ing", "ed"} {
+		if tokens := tokenizer.EncodeString(seed); len(tokens) != 1 {
+			t.Errorf("Expected %q to stay one token after training, got %v", seed, tokens)
+		}
+	}
+
+	if _, err := NewWithSeeds([][]byte{[]byte("ok"), {}}); err == nil {
+		t.Error("Expected an error for an empty seed")
+	}
+}
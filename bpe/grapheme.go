@@ -0,0 +1,149 @@
+package bpe
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// GraphemeClusters splits text into grapheme clusters, the user-perceived
+// characters of Unicode text: a base character with its combining marks,
+// an emoji with its skin tone modifier or joined by zero width joiners, a
+// flag's pair of regional indicators, a Hangul syllable spelled in jamo,
+// or CR LF
+//
+// It follows the extended grapheme cluster rules of Unicode Standard Annex
+// #29, using the general categories in package unicode to classify runes
+// (marks extend, spacing marks attach, symbols after a joiner continue the
+// cluster) rather than the full grapheme break property tables. Invalid
+// UTF-8 bytes are clusters of their own. The returned chunks are subslices
+// of text and together cover it exactly, so it can be used directly as a
+// Tokenizer.Pretokenizer, though usually KeepGraphemes is what you want.
+func GraphemeClusters(text []byte) [][]byte {
+	chunks := [][]byte{}
+	start := 0
+	for _, end := range graphemeBoundaries(text) {
+		chunks = append(chunks, text[start:end])
+		start = end
+	}
+	return chunks
+}
+
+// KeepGraphemes wraps a pretokenizer so no chunk boundary falls inside a
+// grapheme cluster
+// Chunks that would split a cluster are joined back together, so a combining
+// accent or an emoji modifier always stays in the same chunk as the
+// character it modifies and merges can form across the whole cluster.
+// GPT2Pretokenizer, for example, separates "e" from a following U+0301
+// COMBINING ACUTE ACCENT. pretokenizer must return subslices of its input
+// that cover it exactly, as GPT2Pretokenizer does.
+func KeepGraphemes(pretokenizer func([]byte) [][]byte) func([]byte) [][]byte {
+	return func(text []byte) [][]byte {
+		isBoundary := make(map[int]bool)
+		for _, end := range graphemeBoundaries(text) {
+			isBoundary[end] = true
+		}
+
+		chunks := [][]byte{}
+		start, end := 0, 0
+		for _, chunk := range pretokenizer(text) {
+			end += len(chunk)
+			if isBoundary[end] {
+				chunks = append(chunks, text[start:end])
+				start = end
+			}
+		}
+		return chunks
+	}
+}
+
+// graphemeBoundaries returns the end offset of each grapheme cluster in
+// text, in order
+func graphemeBoundaries(text []byte) []int {
+	ends := []int{}
+	prev, size := utf8.DecodeRune(text)
+	regionalRun := 0 // regional indicators in a row ending at prev
+	if isRegionalIndicator(prev) {
+		regionalRun = 1
+	}
+	for i := size; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if !graphemeJoins(prev, r, regionalRun) {
+			ends = append(ends, i)
+		}
+		if isRegionalIndicator(r) {
+			regionalRun++
+		} else {
+			regionalRun = 0
+		}
+		prev = r
+		i += size
+	}
+	if len(text) > 0 {
+		ends = append(ends, len(text))
+	}
+	return ends
+}
+
+// graphemeJoins reports whether rune b continues the grapheme cluster that
+// rune a ends; regionalRun counts the regional indicators ending at a
+func graphemeJoins(a, b rune, regionalRun int) bool {
+	switch {
+	case a == '\r' && b == '\n':
+		return true
+	case isGraphemeControl(a) || isGraphemeControl(b):
+		return false
+	case isHangulJoin(a, b):
+		return true
+	case isGraphemeExtend(b) || unicode.Is(unicode.Mc, b):
+		return true
+	case a == '\u200d' && unicode.Is(unicode.So, b):
+		// Emoji zero width joiner sequences, such as family emoji
+		return true
+	case isRegionalIndicator(a) && isRegionalIndicator(b):
+		// Flags pair indicators up; a third starts a new flag
+		return regionalRun%2 == 1
+	}
+	return false
+}
+
+// isGraphemeControl reports whether r always stands alone in a cluster
+// Invalid UTF-8 decodes to utf8.RuneError and is treated the same way.
+func isGraphemeControl(r rune) bool {
+	return r == utf8.RuneError || r == '\u2028' || r == '\u2029' || unicode.Is(unicode.Cc, r)
+}
+
+// isGraphemeExtend reports whether r attaches to the rune before it:
+// combining marks, the zero width joiner, emoji skin tone modifiers and
+// the tag characters of subdivision flags
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me) ||
+		r == '\u200d' ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) ||
+		(r >= 0xE0020 && r <= 0xE007F)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isHangulJoin reports whether Hangul jamo or syllables a and b belong to
+// the same syllable: leading consonants (L) precede vowels (V) or
+// syllables, and vowels and LV syllables precede trailing consonants (T)
+func isHangulJoin(a, b rune) bool {
+	isL := func(r rune) bool { return (r >= 0x1100 && r <= 0x115F) || (r >= 0xA960 && r <= 0xA97C) }
+	isV := func(r rune) bool { return (r >= 0x1160 && r <= 0x11A7) || (r >= 0xD7B0 && r <= 0xD7C6) }
+	isT := func(r rune) bool { return (r >= 0x11A8 && r <= 0x11FF) || (r >= 0xD7CB && r <= 0xD7FB) }
+	isSyllable := func(r rune) bool { return r >= 0xAC00 && r <= 0xD7A3 }
+	isLV := func(r rune) bool { return isSyllable(r) && (r-0xAC00)%28 == 0 }
+
+	switch {
+	case isL(a):
+		return isL(b) || isV(b) || isSyllable(b)
+	case isV(a) || isLV(a):
+		return isV(b) || isT(b)
+	case isSyllable(a) || isT(a):
+		// An LVT syllable or a trailing consonant only takes more T
+		return isT(b)
+	}
+	return false
+}
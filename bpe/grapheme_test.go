@@ -0,0 +1,114 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf8"
+)
+
+// splitRunes is a pretokenizer that puts every rune in its own chunk
+func splitRunes(text []byte) [][]byte {
+	chunks := [][]byte{}
+	for len(text) > 0 {
+		_, size := utf8.DecodeRune(text)
+		chunks = append(chunks, text[:size])
+		text = text[size:]
+	}
+	return chunks
+}
+
+func TestGraphemeClusters(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"cafe\u0301!", []string{"c", "a", "f", "e\u0301", "!"}},
+		{"ok 👍🏽", []string{"o", "k", " ", "👍🏽"}},
+		{"👨\u200d👩\u200d👧 x", []string{"👨\u200d👩\u200d👧", " ", "x"}},
+		{"🇺🇸🇫🇷🇩", []string{"🇺🇸", "🇫🇷", "🇩"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"각ᄀ", []string{"각", "ᄀ"}},
+		{"नमस्", []string{"न", "म", "स्"}},
+		{"\xffa\u0301", []string{"\xff", "a\u0301"}},
+		{"", []string{}},
+	}
+
+	for _, tc := range cases {
+		chunks := GraphemeClusters([]byte(tc.text))
+		got := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			got[i] = string(chunk)
+		}
+		if len(got) != len(tc.expected) {
+			t.Errorf("%q: expected %q, got %q", tc.text, tc.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Errorf("%q: expected %q, got %q", tc.text, tc.expected, got)
+				break
+			}
+		}
+	}
+}
+
+func TestKeepGraphemesJoinsSplitClusters(t *testing.T) {
+	thumbs := "👍🏽"
+	text := []byte("hi " + thumbs + "!")
+
+	// Splitting by rune separates the emoji from its skin tone modifier
+	if chunks := splitRunes(text); len(chunks) != 6 {
+		t.Fatalf("Expected 6 runes, got %d", len(chunks))
+	}
+
+	chunks := KeepGraphemes(splitRunes)(text)
+	if !bytes.Equal(bytes.Join(chunks, nil), text) {
+		t.Fatal("Expected the chunks to cover the text")
+	}
+	found := false
+	for _, chunk := range chunks {
+		if string(chunk) == thumbs {
+			found = true
+		}
+		if bytes.ContainsRune(chunk, 0x1F3FD) && !bytes.Contains(chunk, []byte(thumbs)) {
+			t.Errorf("Modifier split from its emoji in chunk %q", chunk)
+		}
+	}
+	if !found {
+		t.Errorf("Expected %q as one chunk, got %q", thumbs, chunks)
+	}
+
+	// GPT-2's regex splits a combining accent off the word it belongs to
+	decomposed := []byte("a cafe\u0301 here")
+	if chunks := GPT2Pretokenizer(decomposed); len(chunks) != 4 {
+		t.Fatalf("Expected GPT-2 to split the accent, got %q", chunks)
+	}
+	chunks = KeepGraphemes(GPT2Pretokenizer)(decomposed)
+	expected := []string{"a", " cafe\u0301", " here"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, chunks)
+	}
+	for i := range chunks {
+		if string(chunks[i]) != expected[i] {
+			t.Errorf("Expected %q, got %q", expected, chunks)
+		}
+	}
+}
+
+func TestKeepGraphemesTraining(t *testing.T) {
+	text := bytes.Repeat([]byte("cafe\u0301 👍🏽 ole\u0301 "), 50)
+	tokenizer := New()
+	tokenizer.Pretokenizer = KeepGraphemes(GPT2Pretokenizer)
+	if err := tokenizer.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// The accent now merges with the word it belongs to
+	if _, ok := tokenizer.TokenForBytes([]byte(" cafe\u0301")); !ok {
+		t.Error("Expected \" cafe\\u0301\" to be learned as one token")
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+}
@@ -0,0 +1,270 @@
+package bpe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hfTokenizer is the part of a HuggingFace tokenizer.json that LoadHuggingFace reads
+type hfTokenizer struct {
+	Model struct {
+		Type   string          `json:"type"`
+		Vocab  map[string]int  `json:"vocab"`
+		Merges json.RawMessage `json:"merges"`
+	} `json:"model"`
+	PreTokenizer *struct {
+		Type     string `json:"type"`
+		UseRegex *bool  `json:"use_regex"`
+	} `json:"pre_tokenizer"`
+}
+
+// hfExport is the tokenizer.json layout ExportHuggingFace writes
+type hfExport struct {
+	Version       string         `json:"version"`
+	Truncation    *struct{}      `json:"truncation"`
+	Padding       *struct{}      `json:"padding"`
+	AddedTokens   []hfAddedToken `json:"added_tokens"`
+	Normalizer    *struct{}      `json:"normalizer"`
+	PreTokenizer  hfByteLevel    `json:"pre_tokenizer"`
+	PostProcessor *struct{}      `json:"post_processor"`
+	Decoder       hfByteLevel    `json:"decoder"`
+	Model         hfModel        `json:"model"`
+}
+
+type hfAddedToken struct {
+	ID         int    `json:"id"`
+	Content    string `json:"content"`
+	SingleWord bool   `json:"single_word"`
+	LStrip     bool   `json:"lstrip"`
+	RStrip     bool   `json:"rstrip"`
+	Normalized bool   `json:"normalized"`
+	Special    bool   `json:"special"`
+}
+
+type hfByteLevel struct {
+	Type           string `json:"type"`
+	AddPrefixSpace bool   `json:"add_prefix_space"`
+	TrimOffsets    bool   `json:"trim_offsets"`
+	UseRegex       bool   `json:"use_regex"`
+}
+
+type hfModel struct {
+	Type         string         `json:"type"`
+	Dropout      *float64       `json:"dropout"`
+	UnkToken     *string        `json:"unk_token"`
+	FuseUnk      bool           `json:"fuse_unk"`
+	ByteFallback bool           `json:"byte_fallback"`
+	Vocab        map[string]int `json:"vocab"`
+	Merges       [][2]string    `json:"merges"`
+}
+
+// LoadHuggingFace builds a tokenizer from a byte-level BPE tokenizer.json
+// written by HuggingFace's tokenizers library
+//
+// HuggingFace stores tokens as strings in GPT-2's byte-to-unicode alphabet,
+// where every byte is mapped to a printable character (a space becomes
+// "Ġ"). Each merge's tokens are mapped back to bytes and the merges are
+// replayed in order on top of the usual 256 byte tokens, so token IDs
+// follow this package's numbering rather than the file's. model.vocab is
+// used to check that every merge result is a known token, and a merge that
+// repeats an earlier pair is an error, as in Load. A ByteLevel
+// pre-tokenizer is mapped to GPT2Pretokenizer unless its use_regex is
+// false; added and special tokens are not imported.
+func LoadHuggingFace(r io.Reader) (*Tokenizer, error) {
+	var in hfTokenizer
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, fmt.Errorf("reading tokenizer.json: %w", err)
+	}
+	if in.Model.Type != "" && in.Model.Type != "BPE" {
+		return nil, fmt.Errorf("unsupported model type %q", in.Model.Type)
+	}
+
+	merges, err := parseHFMerges(in.Model.Merges)
+	if err != nil {
+		return nil, err
+	}
+
+	t := New()
+	for i, pair := range merges {
+		var ids [2]int
+		for j, symbol := range pair {
+			tokenBytes, err := gpt2UnicodeToBytes(symbol)
+			if err != nil {
+				return nil, fmt.Errorf("merge %d: %w", i, err)
+			}
+			id, ok := t.TokenForBytes(tokenBytes)
+			if !ok {
+				return nil, fmt.Errorf("merge %d references unknown token %q", i, symbol)
+			}
+			ids[j] = id
+		}
+		if in.Model.Vocab != nil {
+			if _, ok := in.Model.Vocab[pair[0]+pair[1]]; !ok {
+				return nil, fmt.Errorf("merge %d result %q is not in the vocabulary", i, pair[0]+pair[1])
+			}
+		}
+		t.addMerge(ids[0], ids[1])
+	}
+	if err := duplicateMerge(t.Merges); err != nil {
+		return nil, err
+	}
+
+	if in.PreTokenizer != nil && in.PreTokenizer.Type == "ByteLevel" && (in.PreTokenizer.UseRegex == nil || *in.PreTokenizer.UseRegex) {
+		t.Pretokenizer = GPT2Pretokenizer
+	}
+	return t, nil
+}
+
+// ExportHuggingFace writes the tokenizer as a byte-level BPE
+// tokenizer.json that HuggingFace's tokenizers library can load
+//
+// Tokens are written in GPT-2's byte-to-unicode alphabet, as GPT2Token
+// shows them, so a space is "Ġ" and a newline "Ċ". Mapping every byte to
+// one printable character this way doesn't change what BPE learns: each
+// byte is still a single symbol, so the merges counted over bytes are the
+// ones GPT-2's tooling would count over the mapped characters, and only
+// the spelling of the vocabulary differs. The file keeps this package's
+// token IDs, lists merges in the order they apply, and registers special
+// tokens as added tokens. The ByteLevel pre-tokenizer splits with GPT-2's
+// regex when Pretokenizer is set and only maps bytes otherwise; no other
+// Pretokenizer, Normalizer or SpaceMarker can be expressed, so HuggingFace
+// will only encode like Encode for tokenizers without them or with
+// GPT2Pretokenizer. A token whose bytes duplicate an earlier one is left
+// out of the vocabulary, since each string there needs a single ID.
+// LoadHuggingFace reads the file back.
+func (t *Tokenizer) ExportHuggingFace(w io.Writer) error {
+	out := hfExport{
+		Version:     "1.0",
+		AddedTokens: []hfAddedToken{},
+		PreTokenizer: hfByteLevel{
+			Type:        "ByteLevel",
+			TrimOffsets: true,
+			UseRegex:    t.Pretokenizer != nil,
+		},
+		Decoder: hfByteLevel{Type: "ByteLevel", TrimOffsets: true, UseRegex: true},
+		Model: hfModel{
+			Type:   "BPE",
+			Vocab:  make(map[string]int, len(t.Vocabulary)),
+			Merges: make([][2]string, len(t.Merges)),
+		},
+	}
+
+	for id, tokenBytes := range t.Vocabulary {
+		if name, ok := t.specialTokens[id]; ok {
+			out.AddedTokens = append(out.AddedTokens, hfAddedToken{ID: id, Content: name, Special: true})
+			out.Model.Vocab[name] = id
+		} else if owner, ok := t.TokenForBytes(tokenBytes); ok && owner == id {
+			out.Model.Vocab[gpt2BytesToUnicode(tokenBytes)] = id
+		}
+	}
+	for i, merge := range t.Merges {
+		if merge.First < 0 || merge.First >= len(t.Vocabulary) || merge.Second < 0 || merge.Second >= len(t.Vocabulary) {
+			return fmt.Errorf("merge %d references a token outside the vocabulary", i)
+		}
+		out.Model.Merges[i] = [2]string{
+			gpt2BytesToUnicode(t.Vocabulary[merge.First]),
+			gpt2BytesToUnicode(t.Vocabulary[merge.Second]),
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return fmt.Errorf("writing tokenizer.json: %w", err)
+	}
+	return nil
+}
+
+// GPT2Token returns a token's bytes in GPT-2's byte-to-unicode alphabet,
+// the form HuggingFace and GPT-2's vocab.json use: printable bytes stand
+// for themselves and the rest become printable characters, so " the" is
+// "Ġthe"
+// Special tokens are returned by name, and invalid IDs as "".
+func (t *Tokenizer) GPT2Token(id int) string {
+	if name, ok := t.specialTokens[id]; ok {
+		return name
+	}
+	if id < 0 || id >= len(t.Vocabulary) {
+		return ""
+	}
+	return gpt2BytesToUnicode(t.Vocabulary[id])
+}
+
+// parseHFMerges accepts both merge layouts used by tokenizer.json: the
+// older "first second" strings and the newer ["first", "second"] arrays
+func parseHFMerges(raw json.RawMessage) ([][2]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var pairs [][2]string
+	if err := json.Unmarshal(raw, &pairs); err == nil {
+		return pairs, nil
+	}
+
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err != nil {
+		return nil, fmt.Errorf("reading merges: %w", err)
+	}
+	pairs = make([][2]string, len(lines))
+	for i, line := range lines {
+		first, second, ok := strings.Cut(line, " ")
+		if !ok || first == "" || second == "" || strings.Contains(second, " ") {
+			return nil, fmt.Errorf("merge %d is malformed: %q", i, line)
+		}
+		pairs[i] = [2]string{first, second}
+	}
+	return pairs, nil
+}
+
+// gpt2ByteRunes is GPT-2's byte-to-unicode table: printable bytes map to
+// themselves and the rest are shifted to code points from U+0100 upward
+var gpt2ByteRunes = func() [256]rune {
+	var table [256]rune
+	next := rune(256)
+	for b := 0; b < 256; b++ {
+		printable := ('!' <= b && b <= '~') || (0xA1 <= b && b <= 0xAC) || (0xAE <= b && b <= 0xFF)
+		if printable {
+			table[b] = rune(b)
+		} else {
+			table[b] = next
+			next++
+		}
+	}
+	return table
+}()
+
+// gpt2RuneBytes inverts gpt2ByteRunes
+var gpt2RuneBytes = func() map[rune]byte {
+	inverse := make(map[rune]byte, 256)
+	for b, r := range gpt2ByteRunes {
+		inverse[r] = byte(b)
+	}
+	return inverse
+}()
+
+// gpt2BytesToUnicode spells b in GPT-2's byte-to-unicode alphabet
+func gpt2BytesToUnicode(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		sb.WriteRune(gpt2ByteRunes[c])
+	}
+	return sb.String()
+}
+
+// gpt2UnicodeToBytes maps a token string in GPT-2's byte-to-unicode
+// alphabet back to the bytes it stands for
+func gpt2UnicodeToBytes(symbol string) ([]byte, error) {
+	out := make([]byte, 0, len(symbol))
+	for _, r := range symbol {
+		b, ok := gpt2RuneBytes[r]
+		if !ok {
+			return nil, fmt.Errorf("token %q has a character outside the byte-level alphabet", symbol)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
@@ -0,0 +1,157 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// hfSample is a trimmed byte-level tokenizer.json as written by HuggingFace tokenizers
+const hfSample = `{
+  "version": "1.0",
+  "pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false, "trim_offsets": true, "use_regex": true},
+  "model": {
+    "type": "BPE",
+    "vocab": {
+      "d": 0, "e": 1, "h": 2, "l": 3, "o": 4, "r": 5, "w": 6, "Ġ": 7,
+      "he": 8, "ll": 9, "hell": 10, "hello": 11, "Ġw": 12, "or": 13, "Ġwor": 14, "Ġworl": 15, "Ġworld": 16
+    },
+    "merges": ["h e", "l l", "he ll", "hell o", "Ġ w", "o r", "Ġw or", "Ġwor l", "Ġworl d"]
+  }
+}`
+
+func TestLoadHuggingFace(t *testing.T) {
+	tokenizer, err := LoadHuggingFace(strings.NewReader(hfSample))
+	if err != nil {
+		t.Fatalf("LoadHuggingFace failed: %v", err)
+	}
+
+	if len(tokenizer.Merges) != 9 {
+		t.Fatalf("Expected 9 merges, got %d", len(tokenizer.Merges))
+	}
+
+	// HuggingFace encodes "hello world!" as ["hello", "Ġworld", "!"]
+	text := []byte("hello world!")
+	tokens := tokenizer.Encode(text)
+	expected := []string{"hello", " world", "!"}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d (%v)", len(expected), len(tokens), tokens)
+	}
+	for i, id := range tokens {
+		if string(tokenizer.Vocabulary[id]) != expected[i] {
+			t.Errorf("Token %d: expected %q, got %q", i, expected[i], tokenizer.Vocabulary[id])
+		}
+	}
+	if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+		t.Errorf("Expected %q, got %q", text, decoded)
+	}
+}
+
+func TestLoadHuggingFaceMergeArrays(t *testing.T) {
+	// Newer versions of the library write each merge as a two-element array
+	data := `{"model": {"type": "BPE", "vocab": {"a": 0, "Ġ": 1, "Ġa": 2}, "merges": [["Ġ", "a"]]}}`
+
+	tokenizer, err := LoadHuggingFace(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("LoadHuggingFace failed: %v", err)
+	}
+	if tokens := tokenizer.Encode([]byte(" a")); len(tokens) != 1 || string(tokenizer.Vocabulary[tokens[0]]) != " a" {
+		t.Errorf("Expected a single \" a\" token, got %v", tokens)
+	}
+}
+
+func TestLoadHuggingFaceRejectsUnknownToken(t *testing.T) {
+	// "ab" is never produced by an earlier merge
+	data := `{"model": {"type": "BPE", "vocab": {"a": 0, "b": 1, "c": 2, "abc": 3}, "merges": ["ab c"]}}`
+
+	_, err := LoadHuggingFace(strings.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "unknown token") {
+		t.Errorf("Expected unknown token error, got %v", err)
+	}
+}
+
+func TestLoadHuggingFaceRejectsDuplicateMerge(t *testing.T) {
+	data := `{"model": {"type": "BPE", "vocab": {"a": 0, "b": 1, "ab": 2}, "merges": ["a b", "a b"]}}`
+
+	_, err := LoadHuggingFace(strings.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "repeats the pair") {
+		t.Errorf("Expected duplicate merge error, got %v", err)
+	}
+}
+
+func TestExportHuggingFace(t *testing.T) {
+	text := generateVariedText(16 * 1024)
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		if err := tokenizer.Train(text, 400); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		eos := tokenizer.AddSpecialToken("<|endoftext|>")
+
+		var buf bytes.Buffer
+		if err := tokenizer.ExportHuggingFace(&buf); err != nil {
+			t.Fatalf("ExportHuggingFace failed: %v", err)
+		}
+		var exported hfExport
+		if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+			t.Fatalf("Reading the export failed: %v", err)
+		}
+
+		// Space and newline take their GPT-2 spellings; printable bytes don't change
+		for symbol, id := range map[string]int{"Ġ": ' ', "Ċ": '\n', "a": 'a', "<|endoftext|>": eos} {
+			if got, ok := exported.Model.Vocab[symbol]; !ok || got != id {
+				t.Errorf("Expected %q to be token %d, got %d", symbol, id, got)
+			}
+		}
+		if len(exported.AddedTokens) != 1 || exported.AddedTokens[0].ID != eos || !exported.AddedTokens[0].Special {
+			t.Errorf("Expected <|endoftext|> as the only added token, got %+v", exported.AddedTokens)
+		}
+		if exported.PreTokenizer.UseRegex != (pretokenizer != nil) {
+			t.Errorf("Expected use_regex %v, got %v", pretokenizer != nil, exported.PreTokenizer.UseRegex)
+		}
+
+		loaded, err := LoadHuggingFace(&buf)
+		if err != nil {
+			t.Fatalf("LoadHuggingFace failed: %v", err)
+		}
+		sample := text[:2048]
+		tokens := loaded.Encode(sample)
+		if !bytes.Equal(loaded.Decode(tokens), sample) {
+			t.Error("Round-trip through tokenizer.json doesn't decode to the original")
+		}
+		if !equalTokens(tokens, tokenizer.Encode(sample)) {
+			t.Error("Expected the loaded tokenizer to encode like the original")
+		}
+	}
+}
+
+func TestGPT2Token(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train(bytes.Repeat([]byte("in the\n"), 50), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	eos := tokenizer.AddSpecialToken("<|endoftext|>")
+
+	the, ok := tokenizer.TokenForBytes([]byte(" the"))
+	if !ok {
+		t.Fatal("Expected \" the\" to be learned")
+	}
+	cases := map[int]string{
+		the:   "Ġthe",
+		' ':   "Ġ",
+		'\n':  "Ċ",
+		0:     "Ā",
+		0xff:  "ÿ",
+		eos:   "<|endoftext|>",
+		-1:    "",
+		10000: "",
+	}
+	for id, expected := range cases {
+		if got := tokenizer.GPT2Token(id); got != expected {
+			t.Errorf("Token %d: expected %q, got %q", id, expected, got)
+		}
+	}
+}
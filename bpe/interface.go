@@ -0,0 +1,19 @@
+package bpe
+
+// Interface is the encoding and decoding surface of a Tokenizer, for code
+// that takes a tokenizer as a dependency and wants to substitute a fake in
+// tests
+//
+// The vocabulary size is exposed as TotalVocabSize rather than VocabSize:
+// Tokenizer already has an exported VocabSize field, and a type can't have
+// a field and a method with the same name. For a consistent tokenizer the
+// two are equal.
+type Interface interface {
+	Encode(text []byte) []int
+	Decode(tokens []int) []byte
+	EncodeString(s string) []int
+	DecodeString(tokens []int) string
+	TotalVocabSize() int
+}
+
+var _ Interface = (*Tokenizer)(nil)
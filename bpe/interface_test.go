@@ -0,0 +1,49 @@
+package bpe
+
+import "testing"
+
+// byteTokenizer is a minimal Interface implementation mapping each byte to
+// its own token, the way a caller might fake a tokenizer
+type byteTokenizer struct{}
+
+func (byteTokenizer) Encode(text []byte) []int {
+	tokens := make([]int, len(text))
+	for i, b := range text {
+		tokens[i] = int(b)
+	}
+	return tokens
+}
+
+func (byteTokenizer) Decode(tokens []int) []byte {
+	text := make([]byte, len(tokens))
+	for i, id := range tokens {
+		text[i] = byte(id)
+	}
+	return text
+}
+
+func (m byteTokenizer) EncodeString(s string) []int      { return m.Encode([]byte(s)) }
+func (m byteTokenizer) DecodeString(tokens []int) string { return string(m.Decode(tokens)) }
+func (byteTokenizer) TotalVocabSize() int                { return 256 }
+
+func TestInterface(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, impl := range []Interface{tokenizer, byteTokenizer{}} {
+		tokens := impl.EncodeString("lowest")
+		if got := impl.DecodeString(tokens); got != "lowest" {
+			t.Errorf("%T: expected %q, got %q", impl, "lowest", got)
+		}
+		for _, id := range tokens {
+			if id >= impl.TotalVocabSize() {
+				t.Errorf("%T: token %d is outside the vocabulary of %d", impl, id, impl.TotalVocabSize())
+			}
+		}
+	}
+	if Interface(tokenizer).TotalVocabSize() != tokenizer.VocabSize {
+		t.Errorf("Expected TotalVocabSize to match the VocabSize field")
+	}
+}
@@ -0,0 +1,126 @@
+package bpe
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonVersion is bumped whenever the JSON layout changes
+const jsonVersion = 1
+
+// jsonTokenizer is the on-the-wire JSON shape of a Tokenizer
+// The 256 base byte tokens are implicit and the rest of the vocabulary
+// is rebuilt by replaying merges, so files stay small and diff cleanly.
+// Alphabet is only written for tokenizers created by NewWithAlphabet.
+type jsonTokenizer struct {
+	Version       int            `json:"version"`
+	VocabSize     int            `json:"vocab_size"`
+	Alphabet      []byte         `json:"alphabet,omitempty"`
+	Merges        []jsonMerge    `json:"merges"`
+	SpecialTokens map[string]int `json:"special_tokens,omitempty"`
+}
+
+type jsonMerge struct {
+	First  int `json:"first"`
+	Second int `json:"second"`
+	Result int `json:"result"`
+	Count  int `json:"count,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (t *Tokenizer) MarshalJSON() ([]byte, error) {
+	out := jsonTokenizer{
+		Version:   jsonVersion,
+		VocabSize: t.VocabSize,
+		Merges:    make([]jsonMerge, len(t.Merges)),
+	}
+	if t.alphabet != nil {
+		out.Alphabet = t.alphabetBytes()
+	}
+	if len(t.specialTokens) > 0 {
+		out.SpecialTokens = t.SpecialTokens()
+	}
+	for i, merge := range t.Merges {
+		out.Merges[i] = jsonMerge(merge)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+// The vocabulary is reconstructed by replaying the merges in order on top
+// of the byte-level base vocabulary, with special tokens slotted in at
+// their recorded IDs.
+func (t *Tokenizer) UnmarshalJSON(data []byte) error {
+	var in jsonTokenizer
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Version != jsonVersion {
+		return fmt.Errorf("unsupported JSON version %d", in.Version)
+	}
+	return t.restore(in)
+}
+
+// restore replaces t with the tokenizer described by in, rebuilding the
+// vocabulary from the alphabet, merges and special tokens
+// t is left unchanged if in is inconsistent.
+func (t *Tokenizer) restore(in jsonTokenizer) error {
+	fresh := New()
+	if len(in.Alphabet) > 0 {
+		fresh = newAlphabetBase(in.Alphabet)
+	}
+	// Every ID is either a base token, a merge result or a special token, so
+	// the vocabulary size is known up front; nil marks a slot not yet filled
+	size := fresh.VocabSize + len(in.Merges) + len(in.SpecialTokens)
+	if in.VocabSize != size {
+		return fmt.Errorf("vocab size %d does not match %d vocabulary entries", in.VocabSize, size)
+	}
+	fresh.Vocabulary = append(fresh.Vocabulary, make([][]byte, size-fresh.VocabSize)...)
+	free := func(id int) bool {
+		return id >= 0 && id < size && fresh.Vocabulary[id] == nil
+	}
+
+	for name, id := range in.SpecialTokens {
+		if !free(id) {
+			return fmt.Errorf("special token %q reuses token ID %d", name, id)
+		}
+		if fresh.specialTokens == nil {
+			fresh.specialTokens = make(map[int]string)
+		}
+		fresh.Vocabulary[id] = []byte{}
+		fresh.specialTokens[id] = name
+	}
+
+	for i, m := range in.Merges {
+		for _, id := range [2]int{m.First, m.Second} {
+			if id < 0 || id >= size || fresh.Vocabulary[id] == nil {
+				return fmt.Errorf("merge %d references unknown token %d", i, id)
+			}
+			if _, special := fresh.specialTokens[id]; special {
+				return fmt.Errorf("merge %d references special token %d", i, id)
+			}
+		}
+		firstBytes := fresh.Vocabulary[m.First]
+		secondBytes := fresh.Vocabulary[m.Second]
+		if !free(m.Result) {
+			return fmt.Errorf("merge %d result %d is already in the vocabulary", i, m.Result)
+		}
+
+		newBytes := append([]byte{}, firstBytes...)
+		newBytes = append(newBytes, secondBytes...)
+		fresh.Vocabulary[m.Result] = newBytes
+		fresh.Merges = append(fresh.Merges, Merge(m))
+	}
+
+	if err := duplicateMerge(fresh.Merges); err != nil {
+		return err
+	}
+	fresh.VocabSize = in.VocabSize
+
+	t.Vocabulary = fresh.Vocabulary
+	t.Merges = fresh.Merges
+	t.VocabSize = fresh.VocabSize
+	t.specialTokens = fresh.specialTokens
+	t.rebuildIndex()
+	return nil
+}
@@ -0,0 +1,126 @@
+package bpe
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tokenizer := New()
+	trainText := []byte("low lower lowest newer newest")
+
+	err := tokenizer.Train(trainText, 270)
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	data, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var loaded Tokenizer
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if loaded.VocabSize != tokenizer.VocabSize {
+		t.Errorf("Expected vocab size %d, got %d", tokenizer.VocabSize, loaded.VocabSize)
+	}
+	for id, expected := range tokenizer.Vocabulary {
+		if !bytes.Equal(loaded.Vocabulary[id], expected) {
+			t.Errorf("Vocabulary entry %d differs: expected %q, got %q", id, expected, loaded.Vocabulary[id])
+		}
+	}
+
+	// Encoding must be identical before and after the round-trip
+	for _, text := range [][]byte{trainText, []byte("slowest lowers")} {
+		expected := tokenizer.Encode(text)
+		got := loaded.Encode(text)
+		if len(expected) != len(got) {
+			t.Fatalf("Encoding of %q differs: expected %v, got %v", text, expected, got)
+		}
+		for i := range expected {
+			if expected[i] != got[i] {
+				t.Fatalf("Encoding of %q differs: expected %v, got %v", text, expected, got)
+			}
+		}
+	}
+}
+
+func TestJSONOmitsBaseVocabulary(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	data, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	expected := `{"version":1,"vocab_size":258,"merges":[{"first":97,"second":97,"result":256,"count":2},{"first":256,"second":97,"result":257,"count":1}]}`
+	if string(data) != expected {
+		t.Errorf("Unexpected JSON.\nExpected: %s\nGot: %s", expected, data)
+	}
+}
+
+func TestJSONRejectsUnknownMergeReference(t *testing.T) {
+	data := `{"version":1,"vocab_size":257,"merges":[{"first":97,"second":300,"result":256}]}`
+
+	var tokenizer Tokenizer
+	err := json.Unmarshal([]byte(data), &tokenizer)
+	if err == nil || !strings.Contains(err.Error(), "unknown token 300") {
+		t.Errorf("Expected unknown token error, got %v", err)
+	}
+}
+
+func TestJSONRejectsVocabSizeMismatch(t *testing.T) {
+	data := `{"version":1,"vocab_size":300,"merges":[{"first":97,"second":97,"result":256}]}`
+
+	var tokenizer Tokenizer
+	if err := json.Unmarshal([]byte(data), &tokenizer); err == nil {
+		t.Error("Expected error when vocab_size doesn't match the merges")
+	}
+}
+
+func TestJSONRebuildsTokenIndex(t *testing.T) {
+	data := `{"version":1,"vocab_size":258,"merges":[{"first":97,"second":97,"result":256,"count":2},{"first":256,"second":97,"result":257,"count":1}]}`
+
+	var tokenizer Tokenizer
+	if err := json.Unmarshal([]byte(data), &tokenizer); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	id, ok := tokenizer.TokenForBytes([]byte("aaa"))
+	if !ok || id != 257 {
+		t.Errorf("Expected token 257 for \"aaa\", got %d (found=%v)", id, ok)
+	}
+}
+
+func TestJSONSpecialTokens(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train([]byte("aaa"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	data, err := json.Marshal(tokenizer)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var loaded Tokenizer
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if id := loaded.SpecialTokens()["<eos>"]; id != eos {
+		t.Errorf("Expected <eos> = %d, got %d", eos, id)
+	}
+	if loaded.VocabSize != tokenizer.VocabSize {
+		t.Errorf("Expected vocab size %d, got %d", tokenizer.VocabSize, loaded.VocabSize)
+	}
+}
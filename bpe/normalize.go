@@ -0,0 +1,52 @@
+package bpe
+
+import (
+	"bytes"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NFCNormalizer converts text to Unicode Normalization Form C, so that
+// precomposed and decomposed spellings of the same character (such as "é"
+// as U+00E9 or as "e" followed by U+0301) produce the same tokens. Assign it
+// to Tokenizer.Normalizer.
+func NFCNormalizer(text []byte) []byte {
+	return norm.NFC.Bytes(text)
+}
+
+// SentencePieceMarker is the word boundary marker SentencePiece uses
+// ("▁", U+2581), for Tokenizer.SpaceMarker
+const SentencePieceMarker = '\u2581'
+
+// normalize applies the Normalizer, if any, to text, then replaces spaces
+// with the SpaceMarker, if any
+func (t *Tokenizer) normalize(text []byte) []byte {
+	if t.Normalizer != nil {
+		text = t.Normalizer(text)
+	}
+	if t.SpaceMarker != 0 {
+		text = bytes.ReplaceAll(text, []byte{' '}, []byte(string(t.SpaceMarker)))
+	}
+	return text
+}
+
+// unmarkSpaces turns every SpaceMarker in decoded text back into a space
+// The text is rewritten in place and the shortened slice returned.
+func (t *Tokenizer) unmarkSpaces(text []byte) []byte {
+	if t.SpaceMarker == 0 {
+		return text
+	}
+	marker := []byte(string(t.SpaceMarker))
+	out := 0
+	for i := 0; i < len(text); {
+		if bytes.HasPrefix(text[i:], marker) {
+			text[out] = ' '
+			i += len(marker)
+		} else {
+			text[out] = text[i]
+			i++
+		}
+		out++
+	}
+	return text[:out]
+}
@@ -0,0 +1,128 @@
+package bpe
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const (
+	nfcText = "café résumé naïve café"
+	nfdText = "cafe\u0301 re\u0301sume\u0301 nai\u0308ve cafe\u0301" // Same text, decomposed
+)
+
+func TestNFCNormalizerEncode(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Normalizer = NFCNormalizer
+	if err := tokenizer.Train([]byte(strings.Repeat(nfcText+" ", 10)), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	fromNFC := tokenizer.Encode([]byte(nfcText))
+	fromNFD := tokenizer.Encode([]byte(nfdText))
+	if !equalTokens(fromNFC, fromNFD) {
+		t.Errorf("Expected identical tokens, got %v (NFC) and %v (NFD)", fromNFC, fromNFD)
+	}
+	if decoded := tokenizer.Decode(fromNFD); !bytes.Equal(decoded, []byte(nfcText)) {
+		t.Errorf("Expected decoded text in NFC form %q, got %q", nfcText, decoded)
+	}
+	if count := tokenizer.CountTokens([]byte(nfdText)); count != len(fromNFC) {
+		t.Errorf("CountTokens = %d, expected %d", count, len(fromNFC))
+	}
+}
+
+func TestNormalizerAppliedDuringTraining(t *testing.T) {
+	normalized := New()
+	normalized.Normalizer = NFCNormalizer
+	if err := normalized.Train([]byte(strings.Repeat(nfdText+" ", 10)), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	plain := New()
+	if err := plain.Train([]byte(strings.Repeat(nfcText+" ", 10)), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if !equalMerges(normalized.Merges, plain.Merges) {
+		t.Error("Training on NFD text with NFCNormalizer should learn the merges of the NFC text")
+	}
+}
+
+func TestTrainFromReaderNormalizer(t *testing.T) {
+	text := []byte(strings.Repeat(nfdText+"\n", 20))
+
+	expected := New()
+	expected.Normalizer = NFCNormalizer
+	expected.Pretokenizer = GPT2Pretokenizer
+	if err := expected.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// One byte per read splits every combining sequence across reads
+	streamed := New()
+	streamed.Normalizer = NFCNormalizer
+	streamed.Pretokenizer = GPT2Pretokenizer
+	if err := streamed.TrainFromReader(iotest.OneByteReader(bytes.NewReader(text)), 300); err != nil {
+		t.Fatalf("TrainFromReader failed: %v", err)
+	}
+
+	if !equalMerges(streamed.Merges, expected.Merges) {
+		t.Error("TrainFromReader with a Normalizer should learn the same merges as Train")
+	}
+}
+
+func TestSpaceMarker(t *testing.T) {
+	tokenizer := New()
+	tokenizer.SpaceMarker = SentencePieceMarker
+	if err := tokenizer.Train([]byte(strings.Repeat("hello world, wide world of words ", 20)), 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	text := []byte("hello world")
+	tokens := tokenizer.Encode(text)
+	var raw []byte
+	for _, id := range tokens {
+		raw = append(raw, tokenizer.Vocabulary[id]...)
+	}
+	if string(raw) != "hello▁world" {
+		t.Errorf("Expected the tokens to spell %q, got %q", "hello▁world", raw)
+	}
+	if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+		t.Errorf("Expected %q, got %q", text, decoded)
+	}
+	if decoded := tokenizer.DecodeString(tokens); decoded != string(text) {
+		t.Errorf("DecodeString: expected %q, got %q", text, decoded)
+	}
+
+	// Merges learn word-initial pieces that start with the marker
+	if id, ok := tokenizer.TokenForBytes([]byte("▁world")); !ok || !slices.Contains(tokens, id) {
+		t.Errorf("Expected %q to be a single learned token in %v", "▁world", tokens)
+	}
+
+	// Offsets and streaming see spaces too, even with the marker split
+	// across single-byte tokens
+	byteLevel := New()
+	byteLevel.SpaceMarker = SentencePieceMarker
+	split := byteLevel.Encode(text)
+	decoded, offsets := byteLevel.DecodeWithOffsets(split)
+	if string(decoded) != "hello world" {
+		t.Fatalf("Expected %q, got %q", "hello world", decoded)
+	}
+	if last := offsets[len(offsets)-1]; last[1] != len(decoded) {
+		t.Errorf("Expected offsets to end at %d, got %v", len(decoded), offsets)
+	}
+	stream := byteLevel.NewStreamDecoder()
+	var sb strings.Builder
+	for _, id := range split {
+		chunk, err := stream.Write([]int{id})
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		sb.WriteString(chunk)
+	}
+	if sb.String() != "hello world" {
+		t.Errorf("Expected the stream to decode %q, got %q", "hello world", sb.String())
+	}
+}
@@ -0,0 +1,186 @@
+package bpe
+
+// pairEntry is a pair and the count it had when it was pushed
+type pairEntry struct {
+	pair  [2]int
+	count int
+}
+
+// pairQueue is a max-heap of pair counts used to pick the next merge
+//
+// Entries are never updated in place. A pair whose count grows is pushed
+// again with its new count, and entries whose count no longer matches the
+// pair counts map are discarded (or re-pushed at the current count) when
+// they reach the top. Counts only grow through a push, so every live pair
+// always has an entry at least as large as its real count, which is what
+// makes the first valid entry popped the true maximum.
+type pairQueue struct {
+	entries []pairEntry
+	// tieLess reports whether a wins a count tie against b
+	tieLess func(a, b [2]int) bool
+}
+
+// newPairQueue builds a queue holding every pair in pairCounts, breaking
+// count ties with tieLess
+// tieLess must order a given two pairs the same way every time.
+func newPairQueue(pairCounts map[[2]int]int, tieLess func(a, b [2]int) bool) *pairQueue {
+	q := &pairQueue{entries: make([]pairEntry, 0, len(pairCounts)), tieLess: tieLess}
+	for pair, count := range pairCounts {
+		q.entries = append(q.entries, pairEntry{pair: pair, count: count})
+	}
+	for i := len(q.entries)/2 - 1; i >= 0; i-- {
+		q.down(i)
+	}
+	return q
+}
+
+// popMax removes and returns the most frequent pair, with the same
+// tie-breaking as findMaxPair given the same tieLess
+// Pairs rejected by allowed (if non-nil) are dropped from the queue for
+// good, so the filter must give the same answer for a pair every time.
+func (q *pairQueue) popMax(pairCounts map[[2]int]int, allowed func(pair [2]int) bool) ([2]int, int) {
+	for len(q.entries) > 0 {
+		top := q.pop()
+
+		count := pairCounts[top.pair]
+		if count != top.count {
+			// Stale entry; requeue the pair at its real count if it still occurs
+			q.push(top.pair, count)
+			continue
+		}
+		if allowed != nil && !allowed(top.pair) {
+			continue
+		}
+		return top.pair, count
+	}
+	return [2]int{}, 0
+}
+
+// push adds a pair at the given count; pairs with no occurrences are ignored
+func (q *pairQueue) push(pair [2]int, count int) {
+	if count <= 0 {
+		return
+	}
+	q.entries = append(q.entries, pairEntry{pair: pair, count: count})
+	q.up(len(q.entries) - 1)
+}
+
+func (q *pairQueue) pop() pairEntry {
+	entries := q.entries
+	top := entries[0]
+	last := len(entries) - 1
+	entries[0] = entries[last]
+	q.entries = entries[:last]
+	q.down(0)
+	return top
+}
+
+// less orders entries by count (highest first), then by tieLess
+func (q *pairQueue) less(i, j int) bool {
+	a, b := q.entries[i], q.entries[j]
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return q.tieLess(a.pair, b.pair)
+}
+
+func (q *pairQueue) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		q.entries[i], q.entries[parent] = q.entries[parent], q.entries[i]
+		i = parent
+	}
+}
+
+func (q *pairQueue) down(i int) {
+	n := len(q.entries)
+	for {
+		best := i
+		left, right := 2*i+1, 2*i+2
+		if left < n && q.less(left, best) {
+			best = left
+		}
+		if right < n && q.less(right, best) {
+			best = right
+		}
+		if best == i {
+			return
+		}
+		q.entries[i], q.entries[best] = q.entries[best], q.entries[i]
+		i = best
+	}
+}
+
+// PairCounter tracks adjacent pair counts over a token sequence while
+// merges are applied to it, for driving a custom training loop (e.g. with
+// a stopping rule of your own)
+//
+// It is the machinery Train uses: counts are built once by Count, updated
+// incrementally by ApplyMerge for only the pairs around each merged
+// position, and Max picks the next pair from a heap with the same
+// tie-breaking as training. Negative token IDs are boundaries; no pair that
+// touches one is counted or merged. A PairCounter is not safe for
+// concurrent use.
+type PairCounter struct {
+	tokens []int
+	counts map[[2]int]int
+	queue  *pairQueue
+	grown  map[[2]int]struct{}
+}
+
+// NewPairCounter returns an empty PairCounter; call Count to load a sequence
+func NewPairCounter() *PairCounter {
+	return &PairCounter{
+		counts: make(map[[2]int]int),
+		queue:  newPairQueue(nil, pairLess),
+		grown:  make(map[[2]int]struct{}),
+	}
+}
+
+// Count replaces the counter's sequence with a copy of tokens and counts
+// its pairs from scratch
+func (c *PairCounter) Count(tokens []int) {
+	c.tokens = c.tokens[:0]
+	for _, id := range tokens {
+		if id < 0 {
+			id = chunkBoundary
+		}
+		c.tokens = append(c.tokens, id)
+	}
+	c.counts = countPairs(c.tokens)
+	c.queue = newPairQueue(c.counts, pairLess)
+}
+
+// ApplyMerge replaces each occurrence of (first, second), left to right,
+// with merged, updates the counts, and returns the new sequence
+// The returned slice belongs to the counter and is only valid until the
+// next call. Negative IDs are rejected and leave the sequence unchanged.
+func (c *PairCounter) ApplyMerge(first, second, merged int) []int {
+	if first < 0 || second < 0 || merged < 0 {
+		return c.tokens
+	}
+	c.tokens = applyMergeIncremental(c.tokens, first, second, merged, c.counts, 1, c.grown)
+	for pair := range c.grown {
+		c.queue.push(pair, c.counts[pair])
+	}
+	clear(c.grown)
+	return c.tokens
+}
+
+// Max returns the most frequent pair and its count, breaking ties in favor
+// of the smallest pair (by first, then second token ID) like training does
+// The count is 0 when no pairs are left.
+func (c *PairCounter) Max() ([2]int, int) {
+	pair, count := c.queue.popMax(c.counts, nil)
+	// Put it back so Max doesn't consume anything
+	c.queue.push(pair, count)
+	return pair, count
+}
+
+// Frequency returns the current count of pair
+func (c *PairCounter) Frequency(pair [2]int) int {
+	return c.counts[pair]
+}
@@ -0,0 +1,173 @@
+package bpe
+
+import "testing"
+
+// trainByLinearScan replays learnMerges using findMaxPair instead of pairQueue
+func trainByLinearScan(tokenizer *Tokenizer, text []byte, opts TrainOptions) {
+	tokens := tokenizer.trainingTokens(text)
+	pairCounts := countPairs(tokens)
+	allowed := tokenizer.mergeFilter(opts)
+
+	for tokenizer.VocabSize < opts.TargetVocabSize {
+		pair, count := findMaxPair(pairCounts, allowed, nil, tokenizer.tieLess(opts))
+		if count == 0 || count < opts.MinFrequency {
+			break
+		}
+		merged := tokenizer.addMerge(pair[0], pair[1])
+		tokenizer.Merges[len(tokenizer.Merges)-1].Count = count
+		tokens = applyMergeIncremental(tokens, pair[0], pair[1], merged, pairCounts, 1, nil)
+	}
+}
+
+func TestPairQueueMatchesLinearScan(t *testing.T) {
+	texts := [][]byte{generateText(20 * 1024), generateVariedText(20 * 1024)}
+	cases := []TrainOptions{
+		{TargetVocabSize: 1000},
+		{TargetVocabSize: 1000, MinFrequency: 20},
+		{TargetVocabSize: 1000, MaxTokenBytes: 3},
+		{TargetVocabSize: 1000, ForbiddenPairs: map[[2]int]bool{{'e', ' '}: true, {' ', 't'}: true}},
+		{TargetVocabSize: 1000, SkipWhitespaceMerges: true},
+		{TargetVocabSize: 1000, NoMergeAcross: []byte(" e")},
+		{TargetVocabSize: 1000, TieBreak: LongestResult},
+		{TargetVocabSize: 1000, TieBreak: ShortestResult},
+	}
+
+	for _, text := range texts {
+		for _, opts := range cases {
+			withQueue := New()
+			if _, err := withQueue.TrainWithOptions(text, opts); err != nil {
+				t.Fatalf("Training failed: %v", err)
+			}
+
+			linear := New()
+			trainByLinearScan(linear, text, opts)
+
+			if !equalMerges(withQueue.Merges, linear.Merges) {
+				t.Errorf("Merges differ for %+v: queue learned %d, linear scan learned %d",
+					opts, len(withQueue.Merges), len(linear.Merges))
+			}
+		}
+	}
+}
+
+func TestPairQueueMatchesLinearScanLateTraining(t *testing.T) {
+	// Deep into training almost every pair occurs once or twice, so most
+	// merges are picked from a long run of tied, stale-prone heap entries
+	text := generateVariedText(20 * 1024)
+	opts := TrainOptions{TargetVocabSize: 4000}
+
+	withQueue := New()
+	learned, err := withQueue.TrainWithOptions(text, opts)
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if last := withQueue.Merges[learned-1].Count; last > 2 {
+		t.Fatalf("Expected training to reach pairs seen at most twice, last count was %d", last)
+	}
+
+	linear := New()
+	trainByLinearScan(linear, text, opts)
+	if !equalMerges(withQueue.Merges, linear.Merges) {
+		t.Errorf("Merges differ: queue learned %d, linear scan learned %d", len(withQueue.Merges), len(linear.Merges))
+	}
+}
+
+func TestPairQueueSkipsStaleEntries(t *testing.T) {
+	pairCounts := map[[2]int]int{{1, 2}: 5, {3, 4}: 3, {5, 6}: 3}
+	queue := newPairQueue(pairCounts, pairLess)
+
+	// (1, 2) drops below the others without being requeued
+	pairCounts[[2]int{1, 2}] = 1
+
+	expected := []struct {
+		pair  [2]int
+		count int
+	}{
+		{[2]int{3, 4}, 3},
+		{[2]int{5, 6}, 3},
+		{[2]int{1, 2}, 1},
+	}
+	for _, want := range expected {
+		pair, count := queue.popMax(pairCounts, nil)
+		if pair != want.pair || count != want.count {
+			t.Fatalf("Expected %v (%d), got %v (%d)", want.pair, want.count, pair, count)
+		}
+		delete(pairCounts, pair)
+	}
+	if _, count := queue.popMax(pairCounts, nil); count != 0 {
+		t.Errorf("Expected an empty queue, got count %d", count)
+	}
+}
+
+func TestPairCounterMatchesTraining(t *testing.T) {
+	text := generateVariedText(8 * 1024)
+	expected := New()
+	if err := expected.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens := make([]int, len(text))
+	for i, b := range text {
+		tokens[i] = int(b)
+	}
+	counter := NewPairCounter()
+	counter.Count(tokens)
+
+	// Drive training by hand and learn the same merges
+	next := 256
+	for i, merge := range expected.Merges {
+		pair, count := counter.Max()
+		if count == 0 {
+			t.Fatalf("Ran out of pairs at merge %d", i)
+		}
+		if pair != [2]int{merge.First, merge.Second} {
+			t.Fatalf("Merge %d: expected %v, counter picked %v", i, [2]int{merge.First, merge.Second}, pair)
+		}
+		tokens = counter.ApplyMerge(pair[0], pair[1], next)
+		next++
+
+		// Incremental counts agree with a recount
+		if i%20 == 0 {
+			for pair, count := range countPairs(tokens) {
+				if got := counter.Frequency(pair); got != count {
+					t.Fatalf("After merge %d, pair %v counted %d, expected %d", i, pair, got, count)
+				}
+			}
+		}
+	}
+	if !equalTokens(tokens, expected.Encode(text)) {
+		t.Error("Expected the counter's sequence to match Encode")
+	}
+}
+
+func TestPairCounterBoundaries(t *testing.T) {
+	counter := NewPairCounter()
+	counter.Count([]int{1, 2, -5, 1, 2, -1, 2, 1})
+
+	if pair, count := counter.Max(); pair != [2]int{1, 2} || count != 2 {
+		t.Errorf("Expected (1, 2) twice, got %v %d", pair, count)
+	}
+	if counter.Frequency([2]int{2, 1}) != 1 {
+		t.Errorf("Expected (2, 1) once, got %d", counter.Frequency([2]int{2, 1}))
+	}
+	// Max doesn't consume the pair
+	if _, count := counter.Max(); count != 2 {
+		t.Errorf("Expected Max to be repeatable, got count %d", count)
+	}
+
+	tokens := counter.ApplyMerge(1, 2, 10)
+	if !equalTokens(tokens, []int{10, -1, 10, -1, 2, 1}) {
+		t.Errorf("Unexpected tokens after merge: %v", tokens)
+	}
+	if pair, count := counter.Max(); pair != [2]int{2, 1} || count != 1 {
+		t.Errorf("Expected (2, 1) once after the merge, got %v %d", pair, count)
+	}
+	if tokens := counter.ApplyMerge(-1, 2, 11); len(tokens) != 6 {
+		t.Errorf("Expected merges involving boundaries to be ignored, got %v", tokens)
+	}
+
+	counter.ApplyMerge(2, 1, 11)
+	if _, count := counter.Max(); count != 0 {
+		t.Errorf("Expected no pairs left, got count %d", count)
+	}
+}
@@ -0,0 +1,59 @@
+package bpe
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// whitespaceClass matches what Python's re treats as \s in str patterns,
+// which GPT-2's regex runs under. RE2's \s is ASCII-only and leaves out \v,
+// so Unicode separators (\p{Z}, covering no-break and line separators), NEL
+// and the information separators U+001C-U+001F are listed as well.
+const whitespaceClass = `\s\v\x{1c}-\x{1f}\x{85}\p{Z}`
+
+// gpt2Pattern is GPT-2's pre-tokenization regex minus the whitespace rules
+// GPT-2 uses `\s+(?!\S)` to leave the last space of a run attached to the
+// following word. RE2 has no lookahead, so GPT2Pretokenizer handles
+// whitespace runs itself.
+var gpt2Pattern = regexp.MustCompile(`^(?:'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^` + whitespaceClass + `\p{L}\p{N}]+)`)
+
+var whitespacePattern = regexp.MustCompile(`^[` + whitespaceClass + `]+`)
+
+// GPT2Pretokenizer splits text the way GPT-2 does before applying BPE:
+// contractions, words, numbers, and punctuation runs, each optionally with
+// one leading space, plus whitespace runs
+//
+// The returned chunks are subslices of text and together cover it exactly,
+// so encoding stays lossless. Assign it to Tokenizer.Pretokenizer.
+func GPT2Pretokenizer(text []byte) [][]byte {
+	chunks := [][]byte{}
+
+	i := 0
+	for i < len(text) {
+		rest := text[i:]
+
+		if loc := gpt2Pattern.FindIndex(rest); loc != nil {
+			chunks = append(chunks, rest[:loc[1]])
+			i += loc[1]
+			continue
+		}
+
+		if loc := whitespacePattern.FindIndex(rest); loc != nil {
+			end := loc[1]
+			// Emulate `\s+(?!\S)`: when the run is followed by more text,
+			// give its last character back so the next match can claim it
+			if _, size := utf8.DecodeLastRune(rest[:end]); end > size && end < len(rest) {
+				end -= size
+			}
+			chunks = append(chunks, rest[:end])
+			i += end
+			continue
+		}
+
+		// Every byte matches one of the patterns above, but never loop forever
+		chunks = append(chunks, rest[:1])
+		i++
+	}
+
+	return chunks
+}
@@ -0,0 +1,109 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGPT2Pretokenizer(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'll go, you'd stay!", []string{"I", "'ll", " go", ",", " you", "'d", " stay", "!"}},
+		{"abc  def", []string{"abc", " ", " def"}},
+		{"x\n\ny", []string{"x", "\n", "\n", "y"}},
+		{"trailing   ", []string{"trailing", "   "}},
+		{"year 2024", []string{"year", " 2024"}},
+		// Whitespace is Unicode-aware, as in Python's re
+		{"a\v\vb", []string{"a", "\v", "\v", "b"}},
+		{"x\u00a0\u00a0y", []string{"x", "\u00a0", "\u00a0", "y"}},
+		{"end.\u2028Next", []string{"end", ".", "\u2028", "Next"}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		chunks := GPT2Pretokenizer([]byte(tt.text))
+		if len(chunks) != len(tt.expected) {
+			t.Errorf("GPT2Pretokenizer(%q) = %q, expected %q", tt.text, chunks, tt.expected)
+			continue
+		}
+		for i := range chunks {
+			if string(chunks[i]) != tt.expected[i] {
+				t.Errorf("GPT2Pretokenizer(%q) = %q, expected %q", tt.text, chunks, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestGPT2PretokenizerCoversInput(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("The quick brown fox\tjumps  over\r\nthe lazy dog."),
+		{0x00, 0xff, 0xc3, ' ', 'a', 0x80},
+		[]byte("héllo wörld ✓"),
+	}
+
+	for _, input := range inputs {
+		joined := bytes.Join(GPT2Pretokenizer(input), nil)
+		if !bytes.Equal(joined, input) {
+			t.Errorf("Chunks don't reassemble the input.\nExpected: %q\nGot: %q", input, joined)
+		}
+	}
+}
+
+func TestPretokenizerKeepsWordBoundaries(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+
+	text := []byte("the cat saw the dog and the bird then the end")
+	if err := tokenizer.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// No merge may span a word and the space that follows it
+	for id, tokenBytes := range tokenizer.Vocabulary {
+		if len(tokenBytes) > 1 && tokenBytes[len(tokenBytes)-1] == ' ' {
+			t.Errorf("Token %d (%q) crosses a pretokenization boundary", id, tokenBytes)
+		}
+	}
+
+	withSpace := tokenizer.Encode([]byte(" the"))
+	withoutSpace := tokenizer.Encode([]byte("the"))
+	if equalTokens(withSpace, withoutSpace) {
+		t.Errorf("Expected \" the\" and \"the\" to encode differently, both gave %v", withSpace)
+	}
+	if len(withSpace) != 1 || len(withoutSpace) != 1 {
+		t.Errorf("Expected single tokens for \" the\" and \"the\", got %v and %v", withSpace, withoutSpace)
+	}
+
+	decoded := tokenizer.Decode(tokenizer.Encode(text))
+	if !bytes.Equal(decoded, text) {
+		t.Errorf("Decoded text doesn't match original.\nExpected: %s\nGot: %s", text, decoded)
+	}
+}
+
+func TestEncodeWithPretokenizerMatchesPerChunk(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+
+	text := generateText(2048)
+	if err := tokenizer.Train(text, 350); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Encoding the whole text equals encoding each chunk on its own
+	expected := []int{}
+	plain := New()
+	plain.Merges = tokenizer.Merges
+	plain.Vocabulary = tokenizer.Vocabulary
+	plain.VocabSize = tokenizer.VocabSize
+	for _, chunk := range GPT2Pretokenizer(text) {
+		expected = append(expected, plain.Encode(chunk)...)
+	}
+
+	if got := tokenizer.Encode(text); !equalTokens(expected, got) {
+		t.Errorf("Pretokenized encoding differs from per-chunk encoding")
+	}
+}
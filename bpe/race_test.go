@@ -0,0 +1,7 @@
+//go:build race
+
+package bpe
+
+// raceEnabled is set when tests run under the race detector, which makes
+// sync.Pool drop items at random and so breaks allocation counts
+const raceEnabled = true
@@ -0,0 +1,166 @@
+package bpe
+
+import (
+	"io"
+	"runtime"
+)
+
+// readChunkSize is how many bytes TrainFromReader reads at a time
+const readChunkSize = 64 * 1024
+
+// TrainFromReader learns BPE merges from a corpus read from r
+// targetVocabSize is the desired final vocabulary size
+//
+// The corpus is read in fixed-size chunks and the initial pair counts are
+// built as it streams in, carrying the last token of each chunk over so
+// pairs that straddle a chunk boundary are still counted. The raw bytes are
+// never held in memory as a whole, but the token stream is: incremental
+// merging rewrites it after every merge, so it must stay resident for the
+// whole run. Expect memory proportional to the corpus size.
+//
+// When a Pretokenizer is set, the last two pretokenized chunks of each read
+// are held back and prepended to the next read, so a word or contraction
+// split across reads is still pretokenized as one piece (see Encoder for
+// the assumption this makes about the splitter). The result is identical
+// to calling Train with the full corpus.
+//
+// When a Normalizer is set, each read is normalized up to (not including)
+// its last ASCII byte and the rest is carried into the next read. ASCII
+// characters never combine with what precedes them, so for normalizers like
+// NFCNormalizer this matches normalizing the corpus in one go.
+func (t *Tokenizer) TrainFromReader(r io.Reader, targetVocabSize int) error {
+	opts := TrainOptions{TargetVocabSize: targetVocabSize}
+	if err := opts.validate(t); err != nil {
+		return err
+	}
+
+	tokens, pairCounts, err := t.readTrainingTokens(r)
+	if err != nil {
+		return err
+	}
+
+	// Counts gathered while reading are for byte-level tokens; when
+	// continuing training, re-encode with the existing merges and recount
+	if len(t.Merges) > 0 {
+		tokens = t.applyExistingMerges(tokens)
+		pairCounts = countPairsParallel(tokens, runtime.GOMAXPROCS(0))
+	}
+
+	t.learnMerges([]trainingSequence{{tokens: tokens, weight: 1}}, pairCounts, opts)
+	return nil
+}
+
+// readTrainingTokens streams r into a byte-level training token stream,
+// counting adjacent pairs as it goes
+func (t *Tokenizer) readTrainingTokens(r io.Reader) ([]int, map[[2]int]int, error) {
+	tokens := []int{}
+	pairCounts := make(map[[2]int]int)
+
+	// startChunk separates pretokenized chunks; appendBytes never counts a
+	// pair across a chunkBoundary, matching countPairs
+	startChunk := func() {
+		if t.Pretokenizer != nil && len(tokens) > 0 {
+			tokens = append(tokens, chunkBoundary)
+		}
+	}
+	appendBytes := func(data []byte) {
+		start := len(tokens)
+		tokens = t.appendTrainingTokens(tokens, data)
+		for i := max(start, 1); i < len(tokens); i++ {
+			if tokens[i-1] != chunkBoundary && tokens[i] != chunkBoundary {
+				pairCounts[[2]int{tokens[i-1], tokens[i]}]++
+			}
+		}
+	}
+
+	// addText takes normalized text and pretokenizes it if needed
+	pending := []byte{}
+	addText := func(text []byte) {
+		if t.Pretokenizer == nil {
+			appendBytes(text)
+			return
+		}
+		// The final chunks may still change with the next read, so hold
+		// them back
+		chunks, rest := t.finalChunks(append(pending, text...))
+		for _, chunk := range chunks {
+			startChunk()
+			appendBytes(chunk)
+		}
+		pending = append([]byte{}, rest...)
+	}
+
+	buf := make([]byte, readChunkSize)
+	unnormalized := []byte{}
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if t.Normalizer == nil {
+				addText(t.normalize(buf[:n]))
+			} else {
+				// Characters after the last ASCII byte may still combine
+				// with the next read, so hold them back
+				data := append(unnormalized, buf[:n]...)
+				cut := lastASCII(data)
+				if cut > 0 {
+					addText(t.normalize(data[:cut]))
+				}
+				unnormalized = append([]byte{}, data[cut:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(unnormalized) > 0 {
+		addText(t.normalize(unnormalized))
+	}
+	if len(pending) > 0 {
+		for _, chunk := range t.Pretokenizer(pending) {
+			startChunk()
+			appendBytes(chunk)
+		}
+	}
+
+	return tokens, pairCounts, nil
+}
+
+// heldChunks is how many trailing Pretokenizer chunks streamed input holds
+// back: more input can extend the last chunk, and can also join it to the
+// one before, as when "'" and "l" become "'ll"
+const heldChunks = 2
+
+// finalChunks pretokenizes normalized streamed text and returns the chunks
+// that more input can no longer change, along with the rest of text, which
+// must be held back until more arrives or the input ends
+// The last heldChunks chunks are held, and so is a trailing UTF-8
+// character that is still incomplete, since the Pretokenizer would split
+// it off. Both results alias text.
+func (t *Tokenizer) finalChunks(text []byte) ([][]byte, []byte) {
+	cut := incompleteSuffix(text)
+	chunks := t.Pretokenizer(text[:cut])
+	if len(chunks) <= heldChunks {
+		return nil, text
+	}
+
+	held := 0
+	for _, chunk := range chunks[len(chunks)-heldChunks:] {
+		held += len(chunk)
+	}
+	return chunks[:len(chunks)-heldChunks], text[max(cut-held, 0):]
+}
+
+// lastASCII returns the index of the last ASCII byte in data, or 0 if there
+// is none
+func lastASCII(data []byte) int {
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] < 0x80 {
+			return i
+		}
+	}
+	return 0
+}
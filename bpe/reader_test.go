@@ -0,0 +1,136 @@
+package bpe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTrainFromReaderMatchesTrain(t *testing.T) {
+	text := generateText(200 * 1024) // Spans several read chunks
+
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		fromSlice := New()
+		fromSlice.Pretokenizer = pretokenizer
+		if err := fromSlice.Train(text, 400); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		fromReader := New()
+		fromReader.Pretokenizer = pretokenizer
+		if err := fromReader.TrainFromReader(bytes.NewReader(text), 400); err != nil {
+			t.Fatalf("TrainFromReader failed: %v", err)
+		}
+
+		if len(fromReader.Merges) != len(fromSlice.Merges) {
+			t.Fatalf("Expected %d merges, got %d", len(fromSlice.Merges), len(fromReader.Merges))
+		}
+		for i := range fromSlice.Merges {
+			if fromReader.Merges[i] != fromSlice.Merges[i] {
+				t.Fatalf("Merge %d differs: expected %+v, got %+v", i, fromSlice.Merges[i], fromReader.Merges[i])
+			}
+		}
+	}
+}
+
+func TestTrainFromReaderSmallReads(t *testing.T) {
+	text := []byte("low lower lowest  newer newest\n\nwidest")
+
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		fromSlice := New()
+		fromSlice.Pretokenizer = pretokenizer
+		if err := fromSlice.Train(text, 280); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		// Every read returns one byte, so every pair straddles a read
+		fromReader := New()
+		fromReader.Pretokenizer = pretokenizer
+		if err := fromReader.TrainFromReader(iotest.OneByteReader(bytes.NewReader(text)), 280); err != nil {
+			t.Fatalf("TrainFromReader failed: %v", err)
+		}
+
+		if !equalMerges(fromSlice.Merges, fromReader.Merges) {
+			t.Errorf("Merges differ.\nExpected: %v\nGot: %v", fromSlice.Merges, fromReader.Merges)
+		}
+	}
+}
+
+func TestTrainFromReaderContractionsAcrossReads(t *testing.T) {
+	text := bytes.Repeat([]byte("we'll they're I've "), 40)
+	pretokenizers := map[string]func([]byte) [][]byte{
+		"gpt2":           GPT2Pretokenizer,
+		"keep graphemes": KeepGraphemes(GPT2Pretokenizer),
+	}
+	for name, pretokenizer := range pretokenizers {
+		fromSlice := New()
+		fromSlice.Pretokenizer = pretokenizer
+		if err := fromSlice.Train(text, 280); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		// Reads of one byte, and of half of what was asked for, cut
+		// every contraction apart at some point
+		for _, wrap := range []func(io.Reader) io.Reader{iotest.OneByteReader, iotest.HalfReader} {
+			fromReader := New()
+			fromReader.Pretokenizer = pretokenizer
+			if err := fromReader.TrainFromReader(wrap(bytes.NewReader(text)), 280); err != nil {
+				t.Fatalf("TrainFromReader failed: %v", err)
+			}
+			if !equalMerges(fromSlice.Merges, fromReader.Merges) {
+				t.Errorf("%s: merges differ.\nExpected: %v\nGot: %v", name, fromSlice.Merges, fromReader.Merges)
+			}
+		}
+	}
+}
+
+func TestTrainFromReaderContinuesTraining(t *testing.T) {
+	text := []byte("aaabdaaabac low lower lowest")
+
+	fromSlice := New()
+	fromReader := New()
+	for _, tokenizer := range []*Tokenizer{fromSlice, fromReader} {
+		if err := tokenizer.Train(text, 260); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+	}
+
+	if err := fromSlice.Train(text, 265); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if err := fromReader.TrainFromReader(bytes.NewReader(text), 265); err != nil {
+		t.Fatalf("TrainFromReader failed: %v", err)
+	}
+
+	if !equalMerges(fromSlice.Merges, fromReader.Merges) {
+		t.Errorf("Continued training differs.\nExpected: %v\nGot: %v", fromSlice.Merges, fromReader.Merges)
+	}
+}
+
+func TestTrainFromReaderErrors(t *testing.T) {
+	tokenizer := New()
+
+	if err := tokenizer.TrainFromReader(bytes.NewReader([]byte("abc")), 256); err == nil {
+		t.Error("Expected error for target vocab size <= 256")
+	}
+
+	readErr := errors.New("read failed")
+	err := tokenizer.TrainFromReader(iotest.ErrReader(readErr), 300)
+	if !errors.Is(err, readErr) {
+		t.Errorf("Expected the reader's error, got %v", err)
+	}
+}
+
+func equalMerges(a, b []Merge) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package bpe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// formatMagic identifies a serialized tokenizer
+var formatMagic = [4]byte{'B', 'P', 'E', 'T'}
+
+// formatVersion is bumped whenever the on-disk layout changes
+// Version 1 predates special tokens and version 2 predates merge counts;
+// both are still accepted by Load.
+const formatVersion uint32 = 3
+
+// Save writes the tokenizer to w in a versioned binary format
+//
+// Layout (all integers are big-endian uint32):
+//
+//	magic "BPET" | version | vocabSize
+//	vocabCount | vocabCount × (id | length | bytes)
+//	mergeCount | mergeCount × (first | second | result | count)
+//	specialCount | specialCount × (id | length | name)
+//
+// Vocabulary entries and special tokens are written in ascending ID order
+// so the output is deterministic for a given tokenizer.
+func (t *Tokenizer) Save(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.Write(formatMagic[:]); err != nil {
+		return err
+	}
+	if err := writeUint32(bw, formatVersion); err != nil {
+		return err
+	}
+	if err := writeInt(bw, t.VocabSize); err != nil {
+		return err
+	}
+
+	if err := writeInt(bw, len(t.Vocabulary)); err != nil {
+		return err
+	}
+	for id, tokenBytes := range t.Vocabulary {
+		if err := writeInt(bw, id); err != nil {
+			return err
+		}
+		if err := writeInt(bw, len(tokenBytes)); err != nil {
+			return err
+		}
+		if _, err := bw.Write(tokenBytes); err != nil {
+			return err
+		}
+	}
+
+	if err := writeInt(bw, len(t.Merges)); err != nil {
+		return err
+	}
+	for _, merge := range t.Merges {
+		for _, v := range [4]int{merge.First, merge.Second, merge.Result, merge.Count} {
+			if err := writeInt(bw, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	specialIDs := make([]int, 0, len(t.specialTokens))
+	for id := range t.specialTokens {
+		specialIDs = append(specialIDs, id)
+	}
+	sort.Ints(specialIDs)
+
+	if err := writeInt(bw, len(specialIDs)); err != nil {
+		return err
+	}
+	for _, id := range specialIDs {
+		name := t.specialTokens[id]
+		if err := writeInt(bw, id); err != nil {
+			return err
+		}
+		if err := writeInt(bw, len(name)); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(name); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Load reads a tokenizer previously written by Save
+// The loaded tokenizer is validated before it is returned; a file with two
+// merges of the same pair is rejected (see Dedupe)
+func Load(r io.Reader) (*Tokenizer, error) {
+	br := bufio.NewReader(r)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if magic != formatMagic {
+		return nil, errors.New("not a serialized BPE tokenizer")
+	}
+
+	version, err := readUint32(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading version: %w", err)
+	}
+	if version < 1 || version > formatVersion {
+		return nil, fmt.Errorf("unsupported format version %d", version)
+	}
+
+	vocabSize, err := readInt(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading vocab size: %w", err)
+	}
+
+	vocabCount, err := readInt(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading vocabulary: %w", err)
+	}
+	// Entries are stored in ID order; anything else is a corrupt file
+	vocab := [][]byte{}
+	for i := 0; i < vocabCount; i++ {
+		id, err := readInt(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading vocabulary entry %d: %w", i, err)
+		}
+		length, err := readInt(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading vocabulary entry %d: %w", i, err)
+		}
+		tokenBytes, err := readBytes(br, length)
+		if err != nil {
+			return nil, fmt.Errorf("reading vocabulary entry %d: %w", i, err)
+		}
+		if id != i {
+			return nil, fmt.Errorf("vocabulary entry %d has ID %d", i, id)
+		}
+		vocab = append(vocab, tokenBytes)
+	}
+
+	mergeCount, err := readInt(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading merges: %w", err)
+	}
+	mergeFields := 3
+	if version >= 3 {
+		mergeFields = 4
+	}
+	merges := []Merge{}
+	for i := 0; i < mergeCount; i++ {
+		var fields [4]int
+		for j := range mergeFields {
+			if fields[j], err = readInt(br); err != nil {
+				return nil, fmt.Errorf("reading merge %d: %w", i, err)
+			}
+		}
+		merges = append(merges, Merge{First: fields[0], Second: fields[1], Result: fields[2], Count: fields[3]})
+	}
+
+	specials := make(map[int]string)
+	if version >= 2 {
+		specialCount, err := readInt(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading special tokens: %w", err)
+		}
+		for i := 0; i < specialCount; i++ {
+			id, err := readInt(br)
+			if err != nil {
+				return nil, fmt.Errorf("reading special token %d: %w", i, err)
+			}
+			length, err := readInt(br)
+			if err != nil {
+				return nil, fmt.Errorf("reading special token %d: %w", i, err)
+			}
+			name, err := readBytes(br, length)
+			if err != nil {
+				return nil, fmt.Errorf("reading special token %d: %w", i, err)
+			}
+			specials[id] = string(name)
+		}
+	}
+
+	if vocabSize != len(vocab) {
+		return nil, fmt.Errorf("vocab size %d does not match %d vocabulary entries", vocabSize, len(vocab))
+	}
+	for i, merge := range merges {
+		if merge.Result < 0 || merge.Result >= len(vocab) {
+			return nil, fmt.Errorf("merge %d result %d is not in the vocabulary", i, merge.Result)
+		}
+	}
+	if err := duplicateMerge(merges); err != nil {
+		return nil, err
+	}
+
+	for id, name := range specials {
+		if id < 0 || id >= len(vocab) {
+			return nil, fmt.Errorf("special token %q (%d) is not in the vocabulary", name, id)
+		}
+	}
+
+	t := &Tokenizer{
+		Vocabulary:    vocab,
+		Merges:        merges,
+		VocabSize:     vocabSize,
+		specialTokens: specials,
+	}
+	t.rebuildIndex()
+	return t, nil
+}
+
+func writeUint32(w io.Writer, v uint32) error {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func writeInt(w io.Writer, v int) error {
+	if v < 0 || uint64(v) > uint64(^uint32(0)) {
+		return fmt.Errorf("value %d does not fit in the serialized format", v)
+	}
+	return writeUint32(w, uint32(v))
+}
+
+func readUint32(r io.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(buf[:]), nil
+}
+
+func readInt(r io.Reader) (int, error) {
+	v, err := readUint32(r)
+	return int(v), err
+}
+
+// readBytes reads exactly length bytes
+// It reads through a LimitReader so a corrupt length can't force a huge allocation.
+func readBytes(r io.Reader, length int) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
@@ -0,0 +1,217 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	tokenizer := New()
+	trainText := []byte("low lower lowest newer newest")
+
+	err := tokenizer.Train(trainText, 270)
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if loaded.VocabSize != tokenizer.VocabSize {
+		t.Errorf("Expected vocab size %d, got %d", tokenizer.VocabSize, loaded.VocabSize)
+	}
+	if len(loaded.Merges) != len(tokenizer.Merges) {
+		t.Fatalf("Expected %d merges, got %d", len(tokenizer.Merges), len(loaded.Merges))
+	}
+	for i := range tokenizer.Merges {
+		if loaded.Merges[i] != tokenizer.Merges[i] {
+			t.Errorf("Merge %d differs: expected %+v, got %+v", i, tokenizer.Merges[i], loaded.Merges[i])
+		}
+	}
+
+	// A loaded tokenizer must encode identically to the original
+	for _, text := range [][]byte{trainText, []byte("slowest lowers"), []byte("")} {
+		expected := tokenizer.Encode(text)
+		got := loaded.Encode(text)
+		if len(expected) != len(got) {
+			t.Fatalf("Encoding of %q differs: expected %v, got %v", text, expected, got)
+		}
+		for i := range expected {
+			if expected[i] != got[i] {
+				t.Fatalf("Encoding of %q differs: expected %v, got %v", text, expected, got)
+			}
+		}
+		if !bytes.Equal(loaded.Decode(got), text) {
+			t.Errorf("Loaded tokenizer failed to round-trip %q", text)
+		}
+	}
+}
+
+func TestLoadRejectsBadMagic(t *testing.T) {
+	_, err := Load(bytes.NewReader([]byte("JSON{}")))
+	if err == nil {
+		t.Error("Expected error for data without the tokenizer header")
+	}
+}
+
+func TestLoadRejectsTruncatedData(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	truncated := buf.Bytes()[:buf.Len()-5]
+	if _, err := Load(bytes.NewReader(truncated)); err == nil {
+		t.Error("Expected error for truncated data")
+	}
+}
+
+func TestLoadValidatesVocabSize(t *testing.T) {
+	tokenizer := New()
+	tokenizer.VocabSize = 300 // Inconsistent with the 256 vocabulary entries
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := Load(&buf); err == nil {
+		t.Error("Expected error when VocabSize doesn't match the vocabulary")
+	}
+}
+
+func TestLoadValidatesMergeResults(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Merges = append(tokenizer.Merges, Merge{First: 97, Second: 98, Result: 256})
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := Load(&buf); err == nil {
+		t.Error("Expected error when a merge result is missing from the vocabulary")
+	}
+}
+
+func TestLoadRebuildsTokenIndex(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	for id, tokenBytes := range tokenizer.Vocabulary {
+		got, ok := loaded.TokenForBytes(tokenBytes)
+		if !ok || got != id {
+			t.Errorf("TokenForBytes(%q) = %d (found=%v), expected %d", tokenBytes, got, ok, id)
+		}
+	}
+}
+
+func TestSaveLoadSpecialTokens(t *testing.T) {
+	tokenizer := New()
+	bos := tokenizer.AddSpecialToken("<bos>")
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if id, ok := loaded.SpecialTokens()["<bos>"]; !ok || id != bos {
+		t.Errorf("Expected <bos> = %d after load, got %d (found=%v)", bos, id, ok)
+	}
+	loaded.RenderSpecialTokens = true
+	if decoded := loaded.Decode([]int{bos, 'a'}); !bytes.Equal(decoded, []byte("<bos>a")) {
+		t.Errorf("Expected %q, got %q", "<bos>a", decoded)
+	}
+}
+
+func TestLoadVersion1(t *testing.T) {
+	// Version 1 files have no special token section
+	var buf bytes.Buffer
+	buf.Write(formatMagic[:])
+	writeUint32(&buf, 1)
+	writeInt(&buf, 257)
+	writeInt(&buf, 257)
+	for id := 0; id < 256; id++ {
+		writeInt(&buf, id)
+		writeInt(&buf, 1)
+		buf.WriteByte(byte(id))
+	}
+	writeInt(&buf, 256)
+	writeInt(&buf, 2)
+	buf.WriteString("ab")
+	writeInt(&buf, 1)
+	writeInt(&buf, 'a')
+	writeInt(&buf, 'b')
+	writeInt(&buf, 256)
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if tokens := loaded.Encode([]byte("ab")); len(tokens) != 1 || tokens[0] != 256 {
+		t.Errorf("Expected [256], got %v", tokens)
+	}
+	if len(loaded.SpecialTokens()) != 0 {
+		t.Errorf("Expected no special tokens, got %v", loaded.SpecialTokens())
+	}
+}
+
+func TestLoadVersion2(t *testing.T) {
+	// Version 2 files have no merge counts
+	var buf bytes.Buffer
+	buf.Write(formatMagic[:])
+	writeUint32(&buf, 2)
+	writeInt(&buf, 257)
+	writeInt(&buf, 257)
+	for id := 0; id < 256; id++ {
+		writeInt(&buf, id)
+		writeInt(&buf, 1)
+		buf.WriteByte(byte(id))
+	}
+	writeInt(&buf, 256)
+	writeInt(&buf, 2)
+	buf.WriteString("ab")
+	writeInt(&buf, 1)
+	writeInt(&buf, 'a')
+	writeInt(&buf, 'b')
+	writeInt(&buf, 256)
+	writeInt(&buf, 0)
+
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Merges) != 1 || loaded.Merges[0] != (Merge{First: 'a', Second: 'b', Result: 256}) {
+		t.Errorf("Expected the merge (a, b) with no count, got %+v", loaded.Merges)
+	}
+}
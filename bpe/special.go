@@ -0,0 +1,54 @@
+package bpe
+
+// AddSpecialToken registers a reserved control token such as "<bos>" or
+// "<eos>" and returns its ID
+//
+// The token is allocated the next free ID, so it never collides with
+// learned merges (later training continues numbering after it). Its
+// Vocabulary entry is an empty sentinel: Encode never produces special
+// tokens from raw text, and Decode drops them unless RenderSpecialTokens is
+// set, in which case the registered name is written instead.
+//
+// Registering a name that already exists returns the existing ID.
+func (t *Tokenizer) AddSpecialToken(name string) int {
+	for id, existing := range t.specialTokens {
+		if existing == name {
+			return id
+		}
+	}
+
+	if t.specialTokens == nil {
+		t.specialTokens = make(map[int]string)
+	}
+
+	id := t.VocabSize
+	t.Vocabulary = append(t.Vocabulary, []byte{})
+	t.specialTokens[id] = name
+	t.VocabSize++
+	t.fingerprint.Store(nil)
+	return id
+}
+
+// SpecialTokens returns the registered special tokens keyed by name
+// The returned map is a copy and may be modified freely.
+func (t *Tokenizer) SpecialTokens() map[string]int {
+	result := make(map[string]int, len(t.specialTokens))
+	for id, name := range t.specialTokens {
+		result[name] = id
+	}
+	return result
+}
+
+// IsSpecial reports whether id is a registered special token
+func (t *Tokenizer) IsSpecial(id int) bool {
+	_, special := t.specialTokens[id]
+	return special
+}
+
+// TotalVocabSize returns the number of token IDs in use: base byte tokens,
+// learned merges and special tokens together
+// Token IDs are dense, so this is the row count an embedding table needs;
+// for a consistent tokenizer it equals VocabSize.
+func (t *Tokenizer) TotalVocabSize() int {
+	return t.baseVocabSize() + len(t.Merges) + len(t.specialTokens)
+}
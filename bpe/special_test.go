@@ -0,0 +1,127 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAddSpecialToken(t *testing.T) {
+	tokenizer := New()
+
+	bos := tokenizer.AddSpecialToken("<bos>")
+	eos := tokenizer.AddSpecialToken("<eos>")
+
+	if bos != 256 || eos != 257 {
+		t.Errorf("Expected special tokens 256 and 257, got %d and %d", bos, eos)
+	}
+	if tokenizer.VocabSize != 258 {
+		t.Errorf("Expected vocab size 258, got %d", tokenizer.VocabSize)
+	}
+	if len(tokenizer.Vocabulary) != tokenizer.VocabSize {
+		t.Errorf("Expected %d vocabulary entries, got %d", tokenizer.VocabSize, len(tokenizer.Vocabulary))
+	}
+
+	// Registering the same name again returns the original ID
+	if again := tokenizer.AddSpecialToken("<bos>"); again != bos {
+		t.Errorf("Expected re-registration to return %d, got %d", bos, again)
+	}
+
+	specials := tokenizer.SpecialTokens()
+	if len(specials) != 2 || specials["<bos>"] != bos || specials["<eos>"] != eos {
+		t.Errorf("Unexpected special tokens: %v", specials)
+	}
+
+	// The returned map is a copy
+	specials["<pad>"] = 999
+	if _, ok := tokenizer.SpecialTokens()["<pad>"]; ok {
+		t.Error("Modifying the returned map should not register a token")
+	}
+}
+
+func TestSpecialTokensDoNotCollideWithTraining(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 261); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, merge := range tokenizer.Merges {
+		if merge.Result == eos {
+			t.Errorf("Merge %+v reused the special token ID", merge)
+		}
+	}
+	if len(tokenizer.Merges) != 4 {
+		t.Errorf("Expected 4 merges, got %d", len(tokenizer.Merges))
+	}
+}
+
+func TestEncodeNeverEmitsSpecialTokens(t *testing.T) {
+	tokenizer := New()
+	eos := tokenizer.AddSpecialToken("<eos>")
+
+	if err := tokenizer.Train([]byte("<eos> text <eos> text <eos>"), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, id := range tokenizer.Encode([]byte("<eos>")) {
+		if id == eos {
+			t.Error("Encode produced a special token from raw text")
+		}
+	}
+}
+
+func TestDecodeSpecialTokens(t *testing.T) {
+	tokenizer := New()
+	bos := tokenizer.AddSpecialToken("<bos>")
+	eos := tokenizer.AddSpecialToken("<eos>")
+
+	tokens := append([]int{bos}, tokenizer.Encode([]byte("hi"))...)
+	tokens = append(tokens, eos)
+
+	// By default special tokens decode to nothing
+	if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, []byte("hi")) {
+		t.Errorf("Expected %q, got %q", "hi", decoded)
+	}
+
+	tokenizer.RenderSpecialTokens = true
+	if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, []byte("<bos>hi<eos>")) {
+		t.Errorf("Expected %q, got %q", "<bos>hi<eos>", decoded)
+	}
+}
+
+func TestSpecialTokensAreNotIndexedAsBytes(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<pad>")
+
+	if id, ok := tokenizer.TokenForBytes([]byte{}); ok {
+		t.Errorf("Expected no token for empty bytes, got %d", id)
+	}
+}
+
+func TestTotalVocabSizeAndIsSpecial(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	bos := tokenizer.AddSpecialToken("<bos>")
+	eos := tokenizer.AddSpecialToken("<eos>")
+
+	// 256 bytes + 3 merges + 2 special tokens
+	if got := tokenizer.TotalVocabSize(); got != 261 {
+		t.Errorf("Expected total vocab size 261, got %d", got)
+	}
+	if tokenizer.TotalVocabSize() != tokenizer.VocabSize {
+		t.Errorf("Expected TotalVocabSize to equal VocabSize %d", tokenizer.VocabSize)
+	}
+
+	for id := 0; id < tokenizer.TotalVocabSize(); id++ {
+		expected := id == bos || id == eos
+		if got := tokenizer.IsSpecial(id); got != expected {
+			t.Errorf("IsSpecial(%d) = %v, expected %v", id, got, expected)
+		}
+	}
+	if tokenizer.IsSpecial(-1) || tokenizer.IsSpecial(1000) {
+		t.Error("Expected IDs outside the vocabulary not to be special")
+	}
+}
@@ -0,0 +1,91 @@
+package bpe
+
+// Stats summarizes how well a tokenizer compresses a piece of text
+type Stats struct {
+	ByteCount        int     // Length of the text in bytes
+	TokenCount       int     // Number of tokens Encode produces
+	CompressionRatio float64 // ByteCount / TokenCount (0 for empty text)
+	UnusedTokens     int     // Learned tokens never emitted when encoding the text
+}
+
+// Stats encodes text and reports how effective the learned merges are on it
+// A high UnusedTokens count suggests targetVocabSize is larger than this
+// kind of text needs.
+func (t *Tokenizer) Stats(text []byte) Stats {
+	tokens := t.Encode(text)
+
+	stats := Stats{
+		ByteCount:  len(text),
+		TokenCount: len(tokens),
+	}
+	if len(tokens) > 0 {
+		stats.CompressionRatio = float64(len(text)) / float64(len(tokens))
+	}
+
+	used := make(map[int]bool, len(tokens))
+	for _, id := range tokens {
+		used[id] = true
+	}
+	for _, merge := range t.Merges {
+		if !used[merge.Result] {
+			stats.UnusedTokens++
+		}
+	}
+
+	return stats
+}
+
+// RemainingPairCounts encodes text with the learned merges and returns how
+// often each adjacent pair of the resulting tokens occurs
+// Pairs are counted the way training counts them, never across
+// Pretokenizer chunks, so the most frequent pair is the merge that training
+// on text would learn next. Frequent pairs here suggest targetVocabSize ran
+// out before the text was fully compressed.
+func (t *Tokenizer) RemainingPairCounts(text []byte) map[[2]int]int {
+	return countPairs(t.trainingTokens(text))
+}
+
+// CoverageReport describes how much of a text a tokenizer's learned merges
+// cover
+type CoverageReport struct {
+	SingleByteTokens   int     // Tokens covering one byte: base tokens and unknown bytes
+	MultiByteTokens    int     // Learned tokens covering two or more bytes
+	FractionCompressed float64 // Share of the text's bytes inside multi-byte tokens (0 for empty text)
+}
+
+// Coverage encodes text and reports how much of it falls back to
+// single-byte tokens
+// Run it on held-out text: a low FractionCompressed means the text is far
+// from the training domain, or targetVocabSize is too small for it. Bytes
+// are counted after normalization.
+func (t *Tokenizer) Coverage(text []byte) CoverageReport {
+	var report CoverageReport
+	total, compressed := 0, 0
+	for _, id := range t.Encode(text) {
+		width := t.encodedWidth(id)
+		total += width
+		if width > 1 {
+			report.MultiByteTokens++
+			compressed += width
+		} else {
+			report.SingleByteTokens++
+		}
+	}
+	if total > 0 {
+		report.FractionCompressed = float64(compressed) / float64(total)
+	}
+	return report
+}
+
+// ProfileUsage encodes text and returns how many times each token ID is
+// emitted
+// IDs that never appear are absent from the map, and the counts sum to the
+// length of Encode(text). Learned tokens with low counts are candidates for
+// pruning.
+func (t *Tokenizer) ProfileUsage(text []byte) map[int]int {
+	usage := make(map[int]int)
+	for _, id := range t.Encode(text) {
+		usage[id]++
+	}
+	return usage
+}
@@ -0,0 +1,140 @@
+package bpe
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tokenizer := New()
+	text := []byte("low lower lowest")
+	if err := tokenizer.Train(text, 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	stats := tokenizer.Stats(text)
+
+	if stats.ByteCount != len(text) {
+		t.Errorf("Expected byte count %d, got %d", len(text), stats.ByteCount)
+	}
+	if stats.TokenCount != len(tokenizer.Encode(text)) {
+		t.Errorf("Expected token count %d, got %d", len(tokenizer.Encode(text)), stats.TokenCount)
+	}
+	if stats.CompressionRatio <= 1 {
+		t.Errorf("Expected compression ratio > 1, got %f", stats.CompressionRatio)
+	}
+
+	// Intermediate merges (e.g. "lo" inside "low") are consumed by later ones
+	if stats.UnusedTokens == 0 {
+		t.Error("Expected some learned tokens to be unused in the final encoding")
+	}
+	if stats.UnusedTokens > len(tokenizer.Merges) {
+		t.Errorf("Unused tokens %d exceeds the %d learned tokens", stats.UnusedTokens, len(tokenizer.Merges))
+	}
+}
+
+func TestStatsUnrelatedText(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// None of the learned tokens appear in unrelated text
+	stats := tokenizer.Stats([]byte("xyz"))
+	if stats.UnusedTokens != len(tokenizer.Merges) {
+		t.Errorf("Expected all %d learned tokens unused, got %d", len(tokenizer.Merges), stats.UnusedTokens)
+	}
+	if stats.CompressionRatio != 1 {
+		t.Errorf("Expected compression ratio 1, got %f", stats.CompressionRatio)
+	}
+}
+
+func TestStatsEmptyText(t *testing.T) {
+	stats := New().Stats(nil)
+	if stats.ByteCount != 0 || stats.TokenCount != 0 || stats.CompressionRatio != 0 {
+		t.Errorf("Expected zero stats for empty text, got %+v", stats)
+	}
+}
+
+func TestRemainingPairCounts(t *testing.T) {
+	text := []byte("low lower lowest newer newest widest")
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train(text, 262); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	remaining := tokenizer.RemainingPairCounts(text)
+	next, count := findMaxPair(remaining, nil, nil, pairLess)
+	if count == 0 {
+		t.Fatal("Expected pairs to remain after a small training budget")
+	}
+	// Spaces only start chunks, so a pair ending in one would cross a boundary
+	for pair := range remaining {
+		if pair[1] == ' ' {
+			t.Errorf("Pair %v spans two pretokenizer chunks", pair)
+		}
+	}
+
+	// Training one more merge must pick the top remaining pair
+	if err := tokenizer.Train(text, 263); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	last := tokenizer.Merges[len(tokenizer.Merges)-1]
+	if got := [2]int{last.First, last.Second}; got != next {
+		t.Errorf("Expected next merge %v (count %d), got %v", next, count, got)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateText(10*1024), 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	inDomain := tokenizer.Coverage(generateText(2048))
+	outOfDomain := tokenizer.Coverage([]byte("Zwölf Boxkämpfer jagen Viktor quer über den großen Sylter Deich."))
+
+	if inDomain.FractionCompressed <= outOfDomain.FractionCompressed {
+		t.Errorf("Expected better coverage in domain, got %.2f in domain vs %.2f out of domain",
+			inDomain.FractionCompressed, outOfDomain.FractionCompressed)
+	}
+	if inDomain.MultiByteTokens == 0 {
+		t.Error("Expected multi-byte tokens on in-domain text")
+	}
+	if outOfDomain.SingleByteTokens <= outOfDomain.MultiByteTokens {
+		t.Errorf("Expected mostly single-byte tokens out of domain, got %+v", outOfDomain)
+	}
+
+	tokens := tokenizer.Encode(generateText(2048))
+	if inDomain.SingleByteTokens+inDomain.MultiByteTokens != len(tokens) {
+		t.Errorf("Expected %d tokens in total, got %+v", len(tokens), inDomain)
+	}
+	if empty := tokenizer.Coverage(nil); empty != (CoverageReport{}) {
+		t.Errorf("Expected an empty report for empty text, got %+v", empty)
+	}
+}
+
+func TestProfileUsage(t *testing.T) {
+	text := []byte("aaabdaaabac")
+	tokenizer := New()
+	if err := tokenizer.Train(text, 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// "aa" -> 256, "ab" -> 257: [256 257 d 256 257 a c]
+	usage := tokenizer.ProfileUsage(text)
+	expected := map[int]int{256: 2, 257: 2, 'd': 1, 'a': 1, 'c': 1}
+	if !maps.Equal(usage, expected) {
+		t.Errorf("Expected %v, got %v", expected, usage)
+	}
+
+	corpus := generateVariedText(4 * 1024)
+	total := 0
+	for _, count := range tokenizer.ProfileUsage(corpus) {
+		total += count
+	}
+	if tokens := tokenizer.Encode(corpus); total != len(tokens) {
+		t.Errorf("Expected counts to sum to %d tokens, got %d", len(tokens), total)
+	}
+}
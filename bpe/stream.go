@@ -0,0 +1,163 @@
+package bpe
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Decoder decodes a stream of token chunks, such as tokens arriving one at
+// a time from a model, into text
+//
+// A multi-byte UTF-8 character can be split across tokens, and so across
+// chunks. Decoder holds back a trailing incomplete character until a later
+// Write completes it, so every string it returns is cut on a character
+// boundary. Obtain one with NewStreamDecoder; it is not safe for concurrent
+// use.
+type Decoder struct {
+	tokenizer *Tokenizer
+	pending   []byte
+}
+
+// NewStreamDecoder returns a Decoder for the tokenizer
+// The tokenizer must not be modified while the Decoder is in use.
+func (t *Tokenizer) NewStreamDecoder() *Decoder {
+	return &Decoder{tokenizer: t}
+}
+
+// Write decodes tokens and returns the text that is complete so far
+// Bytes of an unfinished UTF-8 character at the end are kept for the next
+// call. Invalid UTF-8 that can't become valid is passed through rather than
+// held. Special tokens follow the tokenizer's RenderSpecialTokens setting.
+// If a token ID isn't in the vocabulary, Write returns an error and the
+// decoder's state is unchanged.
+func (d *Decoder) Write(tokens []int) (string, error) {
+	buf := d.pending
+	for i, id := range tokens {
+		tokenBytes, ok := d.tokenizer.renderToken(id)
+		if !ok {
+			return "", fmt.Errorf("invalid token ID %d at position %d", id, i)
+		}
+		buf = append(buf, tokenBytes...)
+	}
+
+	// The marker is a whole character, so it is never cut in half here
+	cut := incompleteSuffix(buf)
+	out := string(d.tokenizer.unmarkSpaces(buf[:cut]))
+	d.pending = append(buf[:0:0], buf[cut:]...)
+	return out, nil
+}
+
+// Flush returns any held-back bytes and resets the decoder
+// Call it when the stream ends; the result is not valid UTF-8 if the
+// stream stopped partway through a character.
+func (d *Decoder) Flush() string {
+	out := string(d.pending)
+	d.pending = nil
+	return out
+}
+
+// incompleteSuffix returns the offset of a trailing UTF-8 sequence that has
+// a valid start but is missing continuation bytes, or len(b) if there is none
+func incompleteSuffix(b []byte) int {
+	// A sequence is at most utf8.UTFMax bytes, so its start is within the
+	// last UTFMax-1 bytes if it is incomplete
+	for i := len(b) - 1; i >= 0 && i >= len(b)-(utf8.UTFMax-1); i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// Encoder encodes text that arrives in pieces, such as reads from a network
+// connection, emitting tokens as soon as later input can no longer change
+// them
+//
+// The tokens emitted by all Write calls followed by Flush are exactly
+// Encode of the concatenated input. Merges never cross Pretokenizer chunks,
+// so with a Pretokenizer set, chunks that more input can no longer change
+// are emitted right away. The last two chunks are held back, since the
+// next piece may extend the last one or join both (a "'" followed by "l"
+// becomes the contraction "'ll" once another "l" arrives), along with a
+// trailing UTF-8 character that is still incomplete; TrainFromReader makes
+// the same assumption about the splitter. Without a Pretokenizer any merge
+// may span the whole input, so nothing is final until Flush. With a
+// Normalizer, bytes after the last ASCII byte are also held back, since
+// they may combine with the next piece. Obtain one with NewStreamEncoder;
+// it is not safe for concurrent use.
+type Encoder struct {
+	tokenizer    *Tokenizer
+	unnormalized []byte
+	pending      []byte
+}
+
+// NewStreamEncoder returns an Encoder for the tokenizer
+// The tokenizer must not be modified while the Encoder is in use.
+func (t *Tokenizer) NewStreamEncoder() *Encoder {
+	return &Encoder{tokenizer: t}
+}
+
+// Write adds the next piece of input and returns the tokens that are now
+// final, which may be none
+func (e *Encoder) Write(data []byte) []int {
+	t := e.tokenizer
+	if t.Normalizer == nil {
+		return e.encodeFinal(t.normalize(data))
+	}
+
+	// Characters after the last ASCII byte may still combine with the next
+	// piece, so hold them back
+	buf := append(e.unnormalized, data...)
+	cut := lastASCII(buf)
+	var tokens []int
+	if cut > 0 {
+		tokens = e.encodeFinal(t.normalize(buf[:cut]))
+	}
+	e.unnormalized = append(buf[:0:0], buf[cut:]...)
+	return tokens
+}
+
+// Flush encodes everything still held back and resets the encoder
+// Call it when the input ends.
+func (e *Encoder) Flush() []int {
+	t := e.tokenizer
+	var tokens []int
+	if len(e.unnormalized) > 0 {
+		tokens = e.encodeFinal(t.normalize(e.unnormalized))
+	}
+	ranks := t.loadRanks()
+	if t.Pretokenizer == nil {
+		tokens = t.appendChunk(tokens, ranks, e.pending, nil)
+	} else {
+		for _, chunk := range t.Pretokenizer(e.pending) {
+			tokens = t.appendChunk(tokens, ranks, chunk, nil)
+		}
+	}
+	e.unnormalized = nil
+	e.pending = nil
+	return tokens
+}
+
+// encodeFinal adds normalized text after the pending bytes and encodes the
+// Pretokenizer chunks that can no longer change
+func (e *Encoder) encodeFinal(text []byte) []int {
+	t := e.tokenizer
+	buf := append(e.pending, text...)
+	if t.Pretokenizer == nil {
+		e.pending = buf
+		return nil
+	}
+
+	chunks, rest := t.finalChunks(buf)
+	ranks := t.loadRanks()
+	var tokens []int
+	for _, chunk := range chunks {
+		tokens = t.appendChunk(tokens, ranks, chunk, nil)
+	}
+	// Chunks and rest alias buf, so copy before reusing the buffer
+	e.pending = append(buf[:0:0], rest...)
+	return tokens
+}
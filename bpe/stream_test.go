@@ -0,0 +1,223 @@
+package bpe
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStreamDecoderSplitCharacter(t *testing.T) {
+	tokenizer := New()
+	text := "naïve café"
+	tokens := tokenizer.EncodeString(text)
+
+	// Split between the two bytes of "ï" (0xC3 0xAF)
+	split := strings.Index(text, "ï") + 1
+	decoder := tokenizer.NewStreamDecoder()
+
+	first, err := decoder.Write(tokens[:split])
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if first != "na" {
+		t.Errorf("Expected the incomplete character to be held back, got %q", first)
+	}
+	second, err := decoder.Write(tokens[split:])
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if first+second != text {
+		t.Errorf("Expected %q across both writes, got %q + %q", text, first, second)
+	}
+	if rest := decoder.Flush(); rest != "" {
+		t.Errorf("Expected nothing left to flush, got %q", rest)
+	}
+}
+
+func TestStreamDecoderOneTokenAtATime(t *testing.T) {
+	tokenizer := New()
+	text := "日本語 and emoji 🎉 mixed"
+	if err := tokenizer.Train([]byte(text+text), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	decoder := tokenizer.NewStreamDecoder()
+	var sb strings.Builder
+	for _, id := range tokenizer.EncodeString(text) {
+		chunk, err := decoder.Write([]int{id})
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Write returned a partial character: %q", chunk)
+		}
+		sb.WriteString(chunk)
+	}
+	sb.WriteString(decoder.Flush())
+	if sb.String() != text {
+		t.Errorf("Expected %q, got %q", text, sb.String())
+	}
+}
+
+func TestStreamDecoderInvalidInput(t *testing.T) {
+	tokenizer := New()
+	decoder := tokenizer.NewStreamDecoder()
+
+	// A lone continuation byte can never complete, so it isn't held back
+	if out, err := decoder.Write([]int{0x80, 'a'}); err != nil || out != "\x80a" {
+		t.Errorf("Expected %q, got %q (err=%v)", "\x80a", out, err)
+	}
+
+	if _, err := decoder.Write([]int{0xE6}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := decoder.Write([]int{0x97, 9999}); err == nil {
+		t.Error("Expected an error for an invalid token ID")
+	}
+	// The failed write left the held-back byte alone
+	if rest := decoder.Flush(); rest != "\xe6" {
+		t.Errorf("Expected the held-back byte to be flushed, got %q", rest)
+	}
+}
+
+func TestStreamEncoderMatchesEncode(t *testing.T) {
+	text := append(generateVariedText(4*1024), strings.Repeat("café naïve déjà vu ", 20)...)
+	// Cut mid-word and mid-character
+	cuts := []int{1000, strings.Index(string(text), "é") + 1, len(text)}
+	slices.Sort(cuts)
+
+	configs := []struct {
+		name  string
+		setup func(*Tokenizer)
+	}{
+		{"plain", func(*Tokenizer) {}},
+		{"pretokenizer", func(tok *Tokenizer) { tok.Pretokenizer = GPT2Pretokenizer }},
+		{"normalizer and marker", func(tok *Tokenizer) {
+			tok.Pretokenizer = GPT2Pretokenizer
+			tok.Normalizer = NFCNormalizer
+			tok.SpaceMarker = SentencePieceMarker
+		}},
+	}
+	for _, config := range configs {
+		t.Run(config.name, func(t *testing.T) {
+			tokenizer := New()
+			config.setup(tokenizer)
+			if err := tokenizer.Train(text, 400); err != nil {
+				t.Fatalf("Training failed: %v", err)
+			}
+
+			encoder := tokenizer.NewStreamEncoder()
+			var streamed []int
+			start := 0
+			for _, cut := range cuts {
+				streamed = append(streamed, encoder.Write(text[start:cut])...)
+				start = cut
+			}
+			if tokenizer.Pretokenizer != nil && len(streamed) == 0 {
+				t.Error("Expected tokens before Flush when a Pretokenizer is set")
+			}
+			streamed = append(streamed, encoder.Flush()...)
+
+			if expected := tokenizer.Encode(text); !equalTokens(streamed, expected) {
+				t.Errorf("Streamed %d tokens, Encode produced %d", len(streamed), len(expected))
+			}
+		})
+	}
+}
+
+// streamCorpus exercises what a Pretokenizer or Normalizer can see
+// differently in a prefix: contractions, whitespace runs, combining marks,
+// emoji sequences and flags
+var streamCorpus = strings.Repeat("we'll see, they're here and I've been told  it's fine.\n"+
+	"cafe\u0301 nai\u0308ve 👍🏽 👨\u200d👩\u200d👧 🇺🇸🇫🇷 x  \t\n 12,345 'll 're ", 20)
+
+func TestStreamEncoderRandomWrites(t *testing.T) {
+	text := []byte(streamCorpus)
+	configs := []struct {
+		name  string
+		setup func(*Tokenizer)
+	}{
+		{"gpt2", func(tok *Tokenizer) { tok.Pretokenizer = GPT2Pretokenizer }},
+		{"graphemes", func(tok *Tokenizer) { tok.Pretokenizer = GraphemeClusters }},
+		{"keep graphemes", func(tok *Tokenizer) { tok.Pretokenizer = KeepGraphemes(GPT2Pretokenizer) }},
+		{"gpt2 with normalizer and marker", func(tok *Tokenizer) {
+			tok.Pretokenizer = GPT2Pretokenizer
+			tok.Normalizer = NFCNormalizer
+			tok.SpaceMarker = SentencePieceMarker
+		}},
+		{"keep graphemes with normalizer", func(tok *Tokenizer) {
+			tok.Pretokenizer = KeepGraphemes(GPT2Pretokenizer)
+			tok.Normalizer = NFCNormalizer
+		}},
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, config := range configs {
+		tokenizer := New()
+		config.setup(tokenizer)
+		if err := tokenizer.Train(text, 400); err != nil {
+			t.Fatalf("%s: training failed: %v", config.name, err)
+		}
+		expected := tokenizer.Encode(text)
+
+		mismatches := 0
+		for range 100 {
+			encoder := tokenizer.NewStreamEncoder()
+			var streamed []int
+			for start := 0; start < len(text); {
+				end := min(start+1+rng.IntN(7), len(text))
+				streamed = append(streamed, encoder.Write(text[start:end])...)
+				start = end
+			}
+			streamed = append(streamed, encoder.Flush()...)
+			if !equalTokens(streamed, expected) {
+				mismatches++
+			}
+		}
+		if mismatches > 0 {
+			t.Errorf("%s: %d of 100 random splits differ from Encode", config.name, mismatches)
+		}
+	}
+}
+
+func TestStreamEncoderContractionAcrossWrites(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	if err := tokenizer.Train([]byte(streamCorpus), 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, word := range []string{"we'll", "they're"} {
+		encoder := tokenizer.NewStreamEncoder()
+		var streamed []int
+		for i := range len(word) {
+			streamed = append(streamed, encoder.Write([]byte(word[i:i+1]))...)
+		}
+		streamed = append(streamed, encoder.Flush()...)
+		if expected := tokenizer.EncodeString(word); !equalTokens(streamed, expected) {
+			t.Errorf("%q one byte at a time: expected %v, got %v", word, expected, streamed)
+		}
+	}
+}
+
+func TestStreamEncoderWithoutPretokenizerWaitsForFlush(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	encoder := tokenizer.NewStreamEncoder()
+	if tokens := encoder.Write([]byte("aaab")); len(tokens) != 0 {
+		t.Errorf("Expected no final tokens without a Pretokenizer, got %v", tokens)
+	}
+	encoder.Write([]byte("daaa"))
+	encoder.Write([]byte("bac"))
+	if got, expected := encoder.Flush(), tokenizer.EncodeString("aaabdaaabac"); !equalTokens(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	if tokens := encoder.Flush(); len(tokens) != 0 {
+		t.Errorf("Expected an empty Flush after reset, got %v", tokens)
+	}
+}
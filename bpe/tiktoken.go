@@ -0,0 +1,55 @@
+package bpe
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// ExportTiktoken writes the vocabulary in tiktoken's BPE file format: one
+// line per token holding the base64-encoded token bytes and its rank
+//
+// The byte tokens come first, then one line per merge in the order it was
+// learned. The rank written is the token ID, so tiktoken produces the
+// same IDs as Encode; this requires merge results to be numbered in
+// learned order, as training does, and an error is returned otherwise.
+// Special tokens are not written; pass SpecialTokens to tiktoken
+// separately. A merge whose bytes duplicate an earlier token is skipped,
+// since tiktoken needs each byte sequence to have a single rank. For a
+// NewWithAlphabet tokenizer, tiktoken can only encode text within the
+// alphabet.
+func (t *Tokenizer) ExportTiktoken(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	writeToken := func(id int) error {
+		if id < 0 || id >= len(t.Vocabulary) {
+			return fmt.Errorf("token %d is not in the vocabulary", id)
+		}
+		tokenBytes := t.Vocabulary[id]
+		if owner, _ := t.TokenForBytes(tokenBytes); owner != id {
+			return nil
+		}
+		_, err := fmt.Fprintf(bw, "%s %d\n", base64.StdEncoding.EncodeToString(tokenBytes), id)
+		return err
+	}
+
+	base := t.baseVocabSize()
+	for id := 0; id < base; id++ {
+		if err := writeToken(id); err != nil {
+			return err
+		}
+	}
+
+	lastRank := base - 1
+	for i, merge := range t.Merges {
+		if merge.Result <= lastRank {
+			return fmt.Errorf("merge %d result %d is out of learned order", i, merge.Result)
+		}
+		lastRank = merge.Result
+		if err := writeToken(merge.Result); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package bpe
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportTiktoken(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train([]byte("low lower lowest newer newest"), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.ExportTiktoken(&buf); err != nil {
+		t.Fatalf("ExportTiktoken failed: %v", err)
+	}
+
+	seen := make(map[int]bool)
+	lastRank := -1
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			t.Fatalf("Malformed line %q", scanner.Text())
+		}
+		tokenBytes, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			t.Fatalf("Bad base64 in %q: %v", scanner.Text(), err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			t.Fatalf("Bad rank in %q: %v", scanner.Text(), err)
+		}
+
+		if seen[rank] {
+			t.Errorf("Rank %d appears twice", rank)
+		}
+		seen[rank] = true
+		if rank <= lastRank {
+			t.Errorf("Rank %d follows rank %d", rank, lastRank)
+		}
+		lastRank = rank
+		if !bytes.Equal(tokenBytes, tokenizer.Vocabulary[rank]) {
+			t.Errorf("Rank %d: expected bytes %q, got %q", rank, tokenizer.Vocabulary[rank], tokenBytes)
+		}
+	}
+
+	// Every token except the special one is exported
+	if len(seen) != tokenizer.VocabSize-1 {
+		t.Errorf("Expected %d lines, got %d", tokenizer.VocabSize-1, len(seen))
+	}
+}
+
+func TestExportTiktokenRejectsOutOfOrderMerges(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer.Merges[0], tokenizer.Merges[1] = tokenizer.Merges[1], tokenizer.Merges[0]
+
+	if err := tokenizer.ExportTiktoken(&bytes.Buffer{}); err == nil {
+		t.Error("Expected error for merges out of learned order")
+	}
+}
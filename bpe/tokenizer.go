@@ -1,13 +1,32 @@
 package bpe
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"math/rand/v2"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // Tokenizer represents a BPE tokenizer with learned merge rules
+//
+// Concurrency: once training is finished, a Tokenizer is safe for use by
+// multiple goroutines calling the read-only methods (Encode, Decode,
+// CountTokens, EncodeBatch, TokenForBytes, and the like) at the same time.
+// Derived lookup tables are published atomically and scratch buffers are
+// pooled, so no read path writes shared state. Methods that change the
+// vocabulary (Train and its variants, AddSpecialToken) and direct writes to
+// the exported fields must not run concurrently with anything else. After
+// editing Merges or Vocabulary entries directly, call Reindex.
 type Tokenizer struct {
-	// Vocabulary maps token IDs to their byte representations
-	Vocabulary map[int][]byte
+	// Vocabulary holds each token's byte representation, indexed by token
+	// ID. IDs are dense, so len(Vocabulary) == VocabSize. Special tokens
+	// have an empty entry.
+	Vocabulary [][]byte
 
 	// Merges stores the merge rules in the order they were learned
 	// Each merge is a pair of token IDs that should be merged
@@ -15,6 +34,66 @@ type Tokenizer struct {
 
 	// VocabSize is the current size of the vocabulary
 	VocabSize int
+
+	// Pretokenizer, when set, splits input into chunks before BPE runs.
+	// Train and Encode process each chunk independently, so merges never
+	// cross chunk boundaries. It isn't serialized; set it again after
+	// loading a tokenizer that was trained with one.
+	Pretokenizer func([]byte) [][]byte
+
+	// Normalizer, when set, rewrites input text before pretokenization in
+	// every training and encoding method. Use the same Normalizer for
+	// training and encoding, or the learned merges won't match the text they
+	// are applied to. Decode returns the normalized text. Like Pretokenizer,
+	// it isn't serialized.
+	Normalizer func([]byte) []byte
+
+	// SpaceMarker, when non-zero, replaces every space with this rune after
+	// normalization, in training and encoding, the way SentencePiece does
+	// with "▁" (SentencePieceMarker). The marker then starts the following
+	// word, so merges learn word-initial pieces. Decoding turns markers back
+	// into spaces; DecodeToken and Vocabulary keep the raw marker. Text that
+	// already contains the marker decodes with a space in its place. It
+	// isn't serialized.
+	SpaceMarker rune
+
+	// RenderSpecialTokens makes Decode emit a special token's registered
+	// name instead of dropping it
+	RenderSpecialTokens bool
+
+	// UnknownTokenBytes is written by Decode in place of invalid token IDs
+	// (negative, too large, or unused). Nil (the default) skips them
+	// silently; set it to make a buggy upstream visible in the output.
+	UnknownTokenBytes []byte
+
+	// OutOfAlphabet chooses what Encode does with a byte outside a
+	// restricted alphabet (see NewWithAlphabet): emit the unknown token (the
+	// default), drop it, or emit ReplacementToken. Tokenizers created by New
+	// have no such bytes. It isn't serialized.
+	OutOfAlphabet OutOfAlphabet
+
+	// ReplacementToken is the token ID Encode emits for an out-of-alphabet
+	// byte when OutOfAlphabet is ReplaceByte
+	ReplacementToken int
+
+	// specialTokens maps special token IDs to their registered names
+	specialTokens map[int]string
+
+	// byBytes is the reverse index from token bytes to token ID
+	byBytes map[string]int
+
+	// maxTokenBytes is the length of the longest indexed token
+	maxTokenBytes int
+
+	// alphabet maps bytes to base tokens when the base vocabulary isn't all
+	// 256 bytes (see NewWithAlphabet); nil means byte b is token b
+	alphabet *alphabet
+
+	// ranks caches the merge rank lookup used by Encode
+	ranks atomic.Pointer[rankTable]
+
+	// fingerprint caches the costly part of Fingerprint
+	fingerprint atomic.Pointer[fingerprintCache]
 }
 
 // Merge represents a single merge rule
@@ -22,108 +101,895 @@ type Merge struct {
 	First  int // First token ID
 	Second int // Second token ID
 	Result int // Resulting merged token ID
+
+	// Count is how often the pair occurred (weighted, for TrainWeighted)
+	// when training chose it. It is 0 for merges not picked by frequency:
+	// those from AddForcedMerge, MergeVocabulary, CaseInsensitive variants
+	// and imported vocabularies.
+	Count int
 }
 
 // New creates a new BPE tokenizer initialized with byte-level vocabulary
 func New() *Tokenizer {
-	vocab := make(map[int][]byte)
+	vocab := make([][]byte, 256)
 
 	// Initialize with all possible byte values (0-255)
 	for i := 0; i < 256; i++ {
 		vocab[i] = []byte{byte(i)}
 	}
 
-	return &Tokenizer{
+	t := &Tokenizer{
 		Vocabulary: vocab,
 		Merges:     []Merge{},
 		VocabSize:  256,
 	}
+	t.rebuildIndex()
+	return t
+}
+
+// Reset returns the tokenizer to the state New (or NewWithAlphabet, for a
+// restricted alphabet) produces: the byte tokens, no merges and no special
+// tokens other than the unknown byte token
+// The Vocabulary slice and the reverse index are truncated and reused
+// rather than reallocated, which cuts garbage in loops that retrain one
+// instance.
+// Every other exported field is configuration and is kept: Pretokenizer,
+// Normalizer, SpaceMarker, RenderSpecialTokens, UnknownTokenBytes,
+// OutOfAlphabet and ReplacementToken. A ReplacementToken that named a
+// merged token no longer exists afterwards.
+func (t *Tokenizer) Reset() {
+	base := t.alphabetBytes()
+	t.Vocabulary = t.Vocabulary[:min(len(t.Vocabulary), len(base))]
+	for id, b := range base {
+		if id == len(t.Vocabulary) {
+			t.Vocabulary = append(t.Vocabulary, []byte{b})
+		} else if existing := t.Vocabulary[id]; len(existing) != 1 || existing[0] != b {
+			t.Vocabulary[id] = []byte{b}
+		}
+	}
+
+	t.Merges = []Merge{}
+	t.VocabSize = len(base)
+	t.specialTokens = nil
+	if t.alphabet != nil {
+		t.AddSpecialToken(UnknownByteToken)
+	}
+	t.ranks.Store(nil)
+	t.fingerprint.Store(nil)
+	t.rebuildIndex()
+}
+
+// Clone returns a deep copy of the tokenizer
+// Vocabulary, Merges, special tokens and UnknownTokenBytes are copied, so
+// training, pruning or editing the clone leaves t untouched. Pretokenizer
+// and Normalizer are functions and are shared as is.
+func (t *Tokenizer) Clone() *Tokenizer {
+	clone := &Tokenizer{
+		Vocabulary:          make([][]byte, len(t.Vocabulary)),
+		Merges:              slices.Clone(t.Merges),
+		VocabSize:           t.VocabSize,
+		Pretokenizer:        t.Pretokenizer,
+		Normalizer:          t.Normalizer,
+		SpaceMarker:         t.SpaceMarker,
+		RenderSpecialTokens: t.RenderSpecialTokens,
+		UnknownTokenBytes:   slices.Clone(t.UnknownTokenBytes),
+		OutOfAlphabet:       t.OutOfAlphabet,
+		ReplacementToken:    t.ReplacementToken,
+		specialTokens:       maps.Clone(t.specialTokens),
+		byBytes:             maps.Clone(t.byBytes),
+		maxTokenBytes:       t.maxTokenBytes,
+	}
+	for id, tokenBytes := range t.Vocabulary {
+		clone.Vocabulary[id] = slices.Clone(tokenBytes)
+	}
+	if t.alphabet != nil {
+		a := *t.alphabet
+		clone.alphabet = &a
+	}
+	return clone
+}
+
+// TieBreak chooses between pairs that occur equally often during training
+type TieBreak int
+
+const (
+	// LowestID picks the pair with the smallest first token ID, then the
+	// smallest second token ID. It is the default.
+	LowestID TieBreak = iota
+
+	// LongestResult picks the pair whose merged token has the most bytes,
+	// falling back to LowestID among pairs of equal length
+	LongestResult
+
+	// ShortestResult picks the pair whose merged token has the fewest
+	// bytes, falling back to LowestID among pairs of equal length
+	ShortestResult
+)
+
+// TrainOptions configures TrainWithOptions
+type TrainOptions struct {
+	// TargetVocabSize is the desired final vocabulary size (must be > 256,
+	// or greater than the base vocabulary for NewWithAlphabet)
+	TargetVocabSize int
+
+	// MinFrequency stops training early once the most frequent pair occurs
+	// fewer than this many times. Zero means every pair that occurs at
+	// least once may be merged.
+	MinFrequency int
+
+	// MaxTokenBytes caps the byte length of learned tokens. A pair whose
+	// merged token would be longer is skipped in favor of the next most
+	// frequent pair. Zero means no cap.
+	MaxTokenBytes int
+
+	// ForbiddenPairs lists pairs of token IDs that must never be merged
+	// (e.g. a digit followed by a letter). A blocked pair is passed over in
+	// favor of the next most frequent one. It must not change during
+	// training.
+	ForbiddenPairs map[[2]int]bool
+
+	// SkipWhitespaceMerges passes over any pair whose merged bytes would be
+	// entirely ASCII whitespace (such as two spaces), so runs of indentation
+	// don't use up vocabulary slots. Whitespace can still merge with other
+	// bytes, e.g. into " the".
+	SkipWhitespaceMerges bool
+
+	// NoMergeAcross lists bytes that merges never join across: a pair is
+	// passed over when the byte on either side of the join is in the set.
+	// With '\n' in it, no learned token contains a newline, which keeps
+	// tokens inside lines for line-oriented formats.
+	NoMergeAcross []byte
+
+	// TieBreak decides which pair is merged when several share the highest
+	// count. Every strategy gives a total order, so training stays
+	// deterministic.
+	TieBreak TieBreak
+
+	// MergeScorer, if set, replaces raw frequency as the training
+	// objective: each merge goes to the allowed pair with the highest
+	// score, given its current count and the tokenizer as trained so far
+	// (for example, count times the merged token's length to favor
+	// compression). Ties in score are broken by TieBreak. The score must not
+	// be NaN, and pairs rarer than MinFrequency are never considered.
+	// Scores can't be kept in a heap when they don't follow the count, so
+	// every pair is rescored for each merge, which makes training much
+	// slower on large corpora.
+	MergeScorer func(pair [2]int, count int, t *Tokenizer) float64
+
+	// ValidateUTF8 makes training fail with an *InvalidUTF8Error, before
+	// anything is learned, if the text isn't valid UTF-8. Training itself is
+	// byte-level either way; this only reports encoding errors in a corpus.
+	ValidateUTF8 bool
+
+	// Progress, if non-nil, is called after each learned merge with the
+	// number of merges learned so far and the number training is aiming for
+	// (TargetVocabSize minus the vocabulary size when training started).
+	// Training may stop before merged reaches target if it runs out of
+	// pairs. It runs on the training goroutine, so it should return quickly.
+	Progress func(merged int, target int)
+
+	// CompressionCurve, if non-nil, collects the training token count after
+	// each learned merge: entry i is the number of tokens the training text
+	// is down to once merge i is applied, so the curve never goes up and its
+	// last entry is len(Encode(text)). The counts come from the incremental
+	// merging training already does, so collecting them costs nothing extra.
+	// They are weighted for TrainWeighted and cover only the sample with
+	// SampleFraction; CaseInsensitive variants repeat the count of the merge
+	// they were derived from. Entries are appended to the slice it points to.
+	CompressionCurve *[]int
+
+	// MinCompressionGain stops training early once a merge shrinks the
+	// training token count by less than this fraction (e.g. 0.001 for
+	// 0.1%), the point where extra vocabulary barely improves compression.
+	// The merge that falls short is kept. Zero disables the check.
+	MinCompressionGain float64
+
+	// CaseInsensitive learns merges from the text with ASCII letters
+	// lowercased, and adds an uppercase and a capitalized variant of each
+	// learned merge so "THE", "The" and "the" encode to tokens of the same
+	// shape. Encoding and decoding are unchanged and round-trip the original
+	// bytes. The variants count toward TargetVocabSize.
+	CaseInsensitive bool
+
+	// SampleFraction, if set, learns merges from a random contiguous
+	// sample of this fraction of the training token stream instead of the
+	// whole stream, trading some merge quality for speed on huge corpora.
+	// Pair counts are estimated from the sample alone, so rare pairs and
+	// text that only appears outside the sample are missed; the stopping
+	// rules (MinFrequency, MinCompressionGain) see sample counts too. The
+	// rest of the stream plays no part in choosing merges and is not
+	// rewritten. 1 uses the whole stream, the same as 0.
+	SampleFraction float64
+
+	// SampleSeed seeds the choice of sample, so training with the same
+	// seed and text learns the same merges
+	SampleSeed uint64
+
+	// targetRatio stops training once the training text compresses to at
+	// least this many bytes per token; set by TrainToRatio
+	targetRatio float64
+
+	// ctx stops training between merges once it is done; set by
+	// TrainContext
+	ctx context.Context
+}
+
+// InvalidUTF8Error reports invalid UTF-8 found when TrainOptions.ValidateUTF8 is set
+type InvalidUTF8Error struct {
+	Offset int // Byte offset of the first invalid sequence
+	Count  int // Number of invalid sequences in the text
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return fmt.Sprintf("training text has %d invalid UTF-8 sequences (first at byte %d)", e.Count, e.Offset)
+}
+
+// checkUTF8 returns an *InvalidUTF8Error if text isn't valid UTF-8
+func checkUTF8(text []byte) error {
+	if utf8.Valid(text) {
+		return nil
+	}
+
+	err := &InvalidUTF8Error{Offset: -1}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if r == utf8.RuneError && size == 1 {
+			if err.Count == 0 {
+				err.Offset = i
+			}
+			err.Count++
+		}
+		i += size
+	}
+	return err
 }
 
 // Train learns BPE merges from the training text
 // targetVocabSize is the desired final vocabulary size
+//
+// Calling Train on a tokenizer that already has merges continues training:
+// the text is first encoded with the existing merges, and new merges are
+// appended after them until the vocabulary reaches targetVocabSize.
+//
+// Empty text is a no-op rather than an error: nothing is learned and the
+// vocabulary is left unchanged, the same as text too short to contain a
+// pair. An invalid targetVocabSize is still reported.
+//
+// A run of n copies of one byte only supports about log2(n) to 2·log2(n)
+// merges: each doubles the token length until the whole run is a handful
+// of tokens, which then join into one. A corpus that is mostly one long
+// run therefore stops well short of a large target.
 func (t *Tokenizer) Train(text []byte, targetVocabSize int) error {
-	if targetVocabSize <= 256 {
-		return fmt.Errorf("target vocabulary size must be > 256")
+	_, err := t.TrainWithOptions(text, TrainOptions{TargetVocabSize: targetVocabSize})
+	return err
+}
+
+// TrainResult reports how far a training run got
+type TrainResult struct {
+	// Target is the requested vocabulary size
+	Target int
+	// Achieved is the vocabulary size training ended with
+	Achieved int
+	// Merges is the number of merges learned by this run
+	Merges int
+}
+
+// Reached reports whether training reached the requested vocabulary size
+// It is false when the corpus ran out of pairs (or, with options, when
+// MinFrequency or another stopping rule ended training) first.
+func (r TrainResult) Reached() bool {
+	return r.Achieved >= r.Target
+}
+
+// TrainWithResult learns BPE merges like TrainWithOptions and reports the
+// vocabulary size it reached
+// Train stops quietly when the corpus has no pairs left to merge, so a
+// large target on a small corpus can under-deliver; use this to detect it.
+// On error the result is zero.
+func (t *Tokenizer) TrainWithResult(text []byte, opts TrainOptions) (TrainResult, error) {
+	learned, err := t.TrainWithOptions(text, opts)
+	if err != nil {
+		return TrainResult{}, err
+	}
+	return TrainResult{Target: opts.TargetVocabSize, Achieved: t.VocabSize, Merges: learned}, nil
+}
+
+// TrainWithOptions learns BPE merges from the training text
+// It returns the number of merges actually learned, which can be fewer than
+// requested when the corpus runs out of pairs or hits opts.MinFrequency.
+func (t *Tokenizer) TrainWithOptions(text []byte, opts TrainOptions) (int, error) {
+	if err := opts.validate(t); err != nil {
+		return 0, err
+	}
+	if opts.ValidateUTF8 {
+		if err := checkUTF8(text); err != nil {
+			return 0, err
+		}
+	}
+	if len(text) == 0 {
+		// Nothing to learn from; see Train
+		return 0, nil
+	}
+	if opts.CaseInsensitive {
+		text = asciiLower(text)
 	}
 
 	// Start with each byte as a separate token
-	tokens := make([]int, len(text))
-	for i, b := range text {
-		tokens[i] = int(b)
+	tokens := t.trainingTokens(text)
+	if opts.SampleFraction > 0 && opts.SampleFraction < 1 {
+		size := int(opts.SampleFraction * float64(len(tokens)))
+		rng := rand.New(rand.NewPCG(opts.SampleSeed, 0))
+		start := rng.IntN(len(tokens) - size + 1)
+		tokens = tokens[start : start+size]
 	}
 
 	// Build initial pair counts (only done once!)
-	pairCounts := t.countPairs(tokens)
+	pairCounts := countPairsParallel(tokens, runtime.GOMAXPROCS(0))
+
+	return t.learnMerges([]trainingSequence{{tokens: tokens, weight: 1}}, pairCounts, opts), nil
+}
+
+// TrainTokens learns BPE merges from a token sequence instead of raw text
+// targetVocabSize is the desired final vocabulary size
+//
+// tokens are used exactly as given: existing merges are not applied to
+// them first. Pass byte-level tokens to an untrained tokenizer, or the
+// output of Encode to continue training from the current merges; either
+// way the result matches Train on the corresponding text without a
+// Pretokenizer. Special tokens in the sequence (such as document
+// separators) are treated as boundaries that no merge crosses. Every ID
+// must be in the current vocabulary. The caller's slice is not modified.
+func (t *Tokenizer) TrainTokens(tokens []int, targetVocabSize int) error {
+	opts := TrainOptions{TargetVocabSize: targetVocabSize}
+	if err := opts.validate(t); err != nil {
+		return err
+	}
+
+	stream := make([]int, len(tokens))
+	for i, id := range tokens {
+		if id < 0 || id >= t.VocabSize {
+			return fmt.Errorf("token %d at position %d is not in the vocabulary", id, i)
+		}
+		if t.IsSpecial(id) {
+			id = chunkBoundary
+		}
+		stream[i] = id
+	}
+	pairCounts := countPairsParallel(stream, runtime.GOMAXPROCS(0))
+
+	t.learnMerges([]trainingSequence{{tokens: stream, weight: 1}}, pairCounts, opts)
+	return nil
+}
+
+// TrainToRatio learns BPE merges until the training text compresses to at
+// least targetRatio bytes per token, or the vocabulary reaches maxVocab
+//
+// Use it instead of guessing a vocabulary size: the ratio is checked before
+// each merge from a live token count, so it stops at the smallest
+// vocabulary that meets the target. If the text can't reach the target
+// within maxVocab (or runs out of pairs), training stops there without an
+// error; check Stats to see what was achieved. The ratio is measured on the
+// training token stream, counting each SpaceMarker as the space it
+// replaced, which matches Stats(text).CompressionRatio unless a Normalizer
+// changes the length of the text.
+func (t *Tokenizer) TrainToRatio(text []byte, targetRatio float64, maxVocab int) error {
+	if targetRatio <= 0 {
+		return fmt.Errorf("target compression ratio must be > 0")
+	}
+	_, err := t.TrainWithOptions(text, TrainOptions{TargetVocabSize: maxVocab, targetRatio: targetRatio})
+	return err
+}
+
+// TrainContext learns BPE merges like Train, stopping early if ctx is
+// cancelled
+// targetVocabSize is the desired final vocabulary size
+//
+// ctx is checked before each merge. On cancellation TrainContext returns
+// ctx.Err() and keeps the merges learned so far: the tokenizer is
+// consistent and usable, just with a smaller vocabulary, and calling Train
+// again continues from it.
+func (t *Tokenizer) TrainContext(ctx context.Context, text []byte, targetVocabSize int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := t.TrainWithOptions(text, TrainOptions{TargetVocabSize: targetVocabSize, ctx: ctx}); err != nil {
+		return err
+	}
+	if t.VocabSize < targetVocabSize {
+		// Distinguish cancellation from running out of pairs
+		return ctx.Err()
+	}
+	return nil
+}
+
+// trainingSequence is a token stream that merges are learned from
+// Pairs in it count weight times. Merges never cross from one sequence to
+// another, so separate documents can be kept apart.
+type trainingSequence struct {
+	tokens []int
+	weight int
+}
+
+// validate checks the options shared by every training entry point, and
+// that t's existing merges can be trained on
+func (opts TrainOptions) validate(t *Tokenizer) error {
+	if baseSize := t.baseVocabSize(); opts.TargetVocabSize <= baseSize {
+		return fmt.Errorf("target vocabulary size must be > %d", baseSize)
+	}
+	if err := duplicateMerge(t.Merges); err != nil {
+		return err
+	}
+	if opts.MinFrequency < 0 {
+		return fmt.Errorf("minimum frequency must be >= 0")
+	}
+	if opts.MaxTokenBytes < 0 {
+		return fmt.Errorf("maximum token bytes must be >= 0")
+	}
+	if opts.MinCompressionGain < 0 || opts.MinCompressionGain >= 1 {
+		return fmt.Errorf("minimum compression gain must be in [0, 1)")
+	}
+	if opts.SampleFraction < 0 || opts.SampleFraction > 1 {
+		return fmt.Errorf("sample fraction must be in [0, 1]")
+	}
+	if opts.TieBreak < LowestID || opts.TieBreak > ShortestResult {
+		return fmt.Errorf("unknown tie break %d", opts.TieBreak)
+	}
+	return nil
+}
+
+// tieLess returns the order that breaks count ties under opts.TieBreak,
+// reporting whether pair a is preferred over pair b
+// Merged lengths come from the vocabulary, whose existing entries never
+// change, so a pair's place in the order is fixed for the whole run.
+func (t *Tokenizer) tieLess(opts TrainOptions) func(a, b [2]int) bool {
+	if opts.TieBreak == LowestID {
+		return pairLess
+	}
+	longer := opts.TieBreak == LongestResult
+	return func(a, b [2]int) bool {
+		la := len(t.Vocabulary[a[0]]) + len(t.Vocabulary[a[1]])
+		lb := len(t.Vocabulary[b[0]]) + len(t.Vocabulary[b[1]])
+		if la != lb {
+			return (la > lb) == longer
+		}
+		return pairLess(a, b)
+	}
+}
+
+// mergeFilter returns a predicate reporting whether a pair may be merged
+// under opts, or nil when every pair is allowed
+func (t *Tokenizer) mergeFilter(opts TrainOptions) func(pair [2]int) bool {
+	if opts.MaxTokenBytes == 0 && len(opts.ForbiddenPairs) == 0 && !opts.SkipWhitespaceMerges && len(opts.NoMergeAcross) == 0 {
+		return nil
+	}
+
+	var barrier [256]bool
+	for _, b := range opts.NoMergeAcross {
+		barrier[b] = true
+	}
+
+	return func(pair [2]int) bool {
+		if opts.ForbiddenPairs[pair] {
+			return false
+		}
+		if len(opts.NoMergeAcross) > 0 {
+			// Tokens are never empty, so both ends exist
+			left, right := t.Vocabulary[pair[0]], t.Vocabulary[pair[1]]
+			if barrier[left[len(left)-1]] || barrier[right[0]] {
+				return false
+			}
+		}
+		if opts.SkipWhitespaceMerges && isWhitespace(t.Vocabulary[pair[0]]) && isWhitespace(t.Vocabulary[pair[1]]) {
+			return false
+		}
+		return opts.MaxTokenBytes == 0 ||
+			len(t.Vocabulary[pair[0]])+len(t.Vocabulary[pair[1]]) <= opts.MaxTokenBytes
+	}
+}
+
+// isWhitespace reports whether b is non-empty and entirely ASCII whitespace
+func isWhitespace(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\n', '\v', '\f', '\r':
+		default:
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+// countSpaceMarkers returns how many SpaceMarkers the bytes of tokens spell
+// out. A marker is usually split across several byte tokens, so the bytes
+// are matched as a stream, restarting at each chunk boundary.
+func (t *Tokenizer) countSpaceMarkers(tokens []int) int {
+	if t.SpaceMarker == 0 {
+		return 0
+	}
+	marker := []byte(string(t.SpaceMarker))
+	count, matched := 0, 0
+	for _, token := range tokens {
+		if token == chunkBoundary {
+			matched = 0
+			continue
+		}
+		for _, b := range t.Vocabulary[token] {
+			// UTF-8 never starts a character inside another, so a mismatch
+			// only needs to check whether b starts a new marker
+			if b != marker[matched] {
+				matched = 0
+			}
+			if b == marker[matched] {
+				matched++
+				if matched == len(marker) {
+					count++
+					matched = 0
+				}
+			}
+		}
+	}
+	return count
+}
+
+// learnMerges runs the merge loop over prepared token sequences and their
+// pair counts, and returns the number of merges learned
+func (t *Tokenizer) learnMerges(seqs []trainingSequence, pairCounts map[[2]int]int, opts TrainOptions) int {
+	allowed := t.mergeFilter(opts)
+	tieLess := t.tieLess(opts)
+	var queue *pairQueue
+	var score func(pair [2]int, count int) float64
+	if opts.MergeScorer == nil {
+		queue = newPairQueue(pairCounts, tieLess)
+	} else {
+		score = func(pair [2]int, count int) float64 {
+			return opts.MergeScorer(pair, count, t)
+		}
+		if opts.MinFrequency > 0 {
+			filter := allowed
+			allowed = func(pair [2]int) bool {
+				return pairCounts[pair] >= opts.MinFrequency && (filter == nil || filter(pair))
+			}
+		}
+	}
+	grown := make(map[[2]int]struct{})
 
 	// Learn merges until we reach target vocabulary size
-	for t.VocabSize < targetVocabSize {
-		// Find the most frequent pair from our maintained counts
-		pair, count := t.findMaxPair(pairCounts)
+	learned := 0
+	target := opts.TargetVocabSize - t.VocabSize
+
+	// Weighted token and byte counts, tracked for MinCompressionGain,
+	// targetRatio and CompressionCurve. Bytes are counted as in the input,
+	// so each SpaceMarker counts as the one space it replaced.
+	total, byteCount := 0, 0
+	if opts.MinCompressionGain > 0 || opts.targetRatio > 0 || opts.CompressionCurve != nil {
+		for _, seq := range seqs {
+			tokens, size := 0, 0
+			for _, token := range seq.tokens {
+				if token != chunkBoundary {
+					tokens++
+					size += len(t.Vocabulary[token])
+				}
+			}
+			markers := t.countSpaceMarkers(seq.tokens)
+			total += tokens * seq.weight
+			byteCount += (size - markers*(utf8.RuneLen(t.SpaceMarker)-1)) * seq.weight
+		}
+	}
+	for t.VocabSize < opts.TargetVocabSize {
+		if opts.ctx != nil && opts.ctx.Err() != nil {
+			// Cancelled; every merge so far is complete
+			break
+		}
+		if opts.targetRatio > 0 && float64(byteCount) >= opts.targetRatio*float64(total) {
+			// The text already compresses well enough
+			break
+		}
+
+		// Find the most frequent (or best scoring) pair from our
+		// maintained counts
+		var pair [2]int
+		var count int
+		if queue != nil {
+			pair, count = queue.popMax(pairCounts, allowed)
+		} else {
+			pair, count = findMaxPair(pairCounts, allowed, score, tieLess)
+		}
 		if count == 0 {
 			// No more pairs to merge
 			break
 		}
+		if count < opts.MinFrequency {
+			// Remaining pairs are too rare to be worth a vocabulary slot
+			break
+		}
 
-		// Create new token for this merge
-		newTokenID := t.VocabSize
+		// Create new token for this merge and record the merge rule
+		newTokenID := t.addMerge(pair[0], pair[1])
+		t.Merges[len(t.Merges)-1].Count = count
 
-		// Add to vocabulary (concatenate the two tokens)
-		firstBytes := t.Vocabulary[pair[0]]
-		secondBytes := t.Vocabulary[pair[1]]
-		newBytes := append([]byte{}, firstBytes...)
-		newBytes = append(newBytes, secondBytes...)
-		t.Vocabulary[newTokenID] = newBytes
+		// Apply the merge to tokens AND update pair counts incrementally;
+		// every merged position removes one token
+		reduced := 0
+		for i := range seqs {
+			before := len(seqs[i].tokens)
+			seqs[i].tokens = applyMergeIncremental(seqs[i].tokens, pair[0], pair[1], newTokenID, pairCounts, seqs[i].weight, grown)
+			reduced += (before - len(seqs[i].tokens)) * seqs[i].weight
+		}
 
-		// Record the merge
-		t.Merges = append(t.Merges, Merge{
-			First:  pair[0],
-			Second: pair[1],
-			Result: newTokenID,
-		})
+		// Requeue the pairs whose counts went up at their new counts
+		if queue != nil {
+			for p := range grown {
+				queue.push(p, pairCounts[p])
+			}
+		}
+		clear(grown)
 
-		// Apply the merge to tokens AND update pair counts incrementally
-		tokens = t.applyMergeIncremental(tokens, pair[0], pair[1], newTokenID, pairCounts)
+		merged := 1
+		if opts.CaseInsensitive {
+			merged += t.addCaseVariants(pair, opts.TargetVocabSize)
+		}
+		learned += merged
+		if opts.Progress != nil {
+			opts.Progress(learned, target)
+		}
 
-		t.VocabSize++
+		before := total
+		total -= reduced
+		if opts.CompressionCurve != nil {
+			for range merged {
+				*opts.CompressionCurve = append(*opts.CompressionCurve, total)
+			}
+		}
+		if opts.MinCompressionGain > 0 && float64(reduced) < opts.MinCompressionGain*float64(before) {
+			// Compression has plateaued
+			break
+		}
 	}
 
-	return nil
+	return learned
+}
+
+// chunkBoundary separates pretokenized chunks in the training token stream
+// Pairs involving it are never counted, so merges can't cross chunks.
+const chunkBoundary = -1
+
+// trainingTokens converts text to the token stream training starts from
+// When a Pretokenizer is set, chunks are separated by chunkBoundary. If
+// the tokenizer already has merges, they are applied first so training
+// continues from the current vocabulary instead of relearning it.
+func (t *Tokenizer) trainingTokens(text []byte) []int {
+	text = t.normalize(text)
+	if t.Pretokenizer == nil {
+		tokens := t.appendTrainingTokens(make([]int, 0, len(text)), text)
+		return t.applyExistingMerges(tokens)
+	}
+
+	tokens := make([]int, 0, len(text))
+	for i, chunk := range t.Pretokenizer(text) {
+		if i > 0 {
+			tokens = append(tokens, chunkBoundary)
+		}
+		tokens = t.appendTrainingTokens(tokens, chunk)
+	}
+	return t.applyExistingMerges(tokens)
+}
+
+// applyExistingMerges encodes a training token stream with the merges the
+// tokenizer already has, one chunkBoundary-delimited segment at a time
+func (t *Tokenizer) applyExistingMerges(tokens []int) []int {
+	if len(t.Merges) == 0 {
+		return tokens
+	}
+
+	ranks := t.loadRanks()
+	out := 0
+	start := 0
+	for start <= len(tokens) {
+		end := start
+		for end < len(tokens) && tokens[end] != chunkBoundary {
+			end++
+		}
+
+		// apply compacts in place, so the merged segment can be shifted down
+		merged := ranks.apply(tokens[start:end], nil)
+		out += copy(tokens[out:], merged)
+		if end < len(tokens) {
+			tokens[out] = chunkBoundary
+			out++
+		}
+		start = end + 1
+	}
+	return tokens[:out]
+}
+
+// addMerge allocates the next token ID for the pair (first, second),
+// stores its bytes, records the merge rule, and returns the new ID
+func (t *Tokenizer) addMerge(first, second int) int {
+	newTokenID := t.VocabSize
+
+	// Add to vocabulary (concatenate the two tokens)
+	firstBytes := t.Vocabulary[first]
+	secondBytes := t.Vocabulary[second]
+	newBytes := append([]byte{}, firstBytes...)
+	newBytes = append(newBytes, secondBytes...)
+	t.Vocabulary = append(t.Vocabulary, newBytes)
+	t.indexToken(newTokenID, newBytes)
+
+	// A rank table that is current can be extended with the new merge, so
+	// adding merges one at a time doesn't rebuild it each time. One that
+	// isn't may be left from before Merges was truncated, and once Merges
+	// grows back over the same array it would look current, so it's
+	// dropped. The fingerprint can't be extended and is always dropped.
+	table := t.ranks.Load()
+	current := table != nil && table.matches(t.Merges)
+	t.Merges = append(t.Merges, Merge{
+		First:  first,
+		Second: second,
+		Result: newTokenID,
+	})
+	if current {
+		table.add(t.Merges)
+	} else {
+		t.ranks.Store(nil)
+	}
+	t.fingerprint.Store(nil)
+
+	t.VocabSize++
+	return newTokenID
+}
+
+// TokenForBytes returns the token ID whose vocabulary entry is exactly b
+// If several tokens share the same bytes, the lowest ID is returned.
+func (t *Tokenizer) TokenForBytes(b []byte) (int, bool) {
+	id, ok := t.byBytes[string(b)]
+	return id, ok
+}
+
+// indexToken records id in the reverse byte index unless an earlier token
+// already owns the same bytes
+func (t *Tokenizer) indexToken(id int, b []byte) {
+	if t.byBytes == nil {
+		t.byBytes = make(map[string]int)
+	}
+	t.maxTokenBytes = max(t.maxTokenBytes, len(b))
+	if existing, ok := t.byBytes[string(b)]; ok && existing < id {
+		return
+	}
+	t.byBytes[string(b)] = id
+}
+
+// Reindex rebuilds the lookup tables derived from Vocabulary and Merges
+// Methods that change the vocabulary keep these tables current, but they
+// can't see direct edits to the exported fields. Call Reindex after
+// changing an entry of Merges or Vocabulary in place, or after truncating
+// Merges and appending to it by hand, so Encode, TokenForBytes, MergeRanks,
+// Fingerprint and the like use the current contents. Assigning a new Merges slice is
+// noticed without it.
+func (t *Tokenizer) Reindex() {
+	t.ranks.Store(nil)
+	t.fingerprint.Store(nil)
+	t.rebuildIndex()
+}
+
+// rebuildIndex recomputes the reverse byte index from Vocabulary
+// Called after the vocabulary is replaced wholesale (e.g. by Load)
+// The existing map is reused if there is one. The byte alphabet is
+// derived from the index, so it is rebuilt too.
+func (t *Tokenizer) rebuildIndex() {
+	if t.byBytes == nil {
+		t.byBytes = make(map[string]int, len(t.Vocabulary))
+	} else {
+		clear(t.byBytes)
+	}
+	t.maxTokenBytes = 0
+	for id, b := range t.Vocabulary {
+		if _, special := t.specialTokens[id]; special {
+			continue
+		}
+		t.indexToken(id, b)
+	}
+	t.rebuildAlphabet()
 }
 
 // Encode converts text into token IDs using the learned merges
+//
+// The result is the same as applying every merge, in the order it was
+// learned, across the whole sequence. Instead of rescanning the tokens once
+// per merge, Encode keeps a priority queue of the applicable pairs keyed by
+// merge rank, so the cost depends on the text length rather than on the
+// number of learned merges.
+//
+// When a Pretokenizer is set, each chunk is encoded independently and the
+// results are concatenated. A Normalizer, if set, runs before either.
 func (t *Tokenizer) Encode(text []byte) []int {
-	// Start with byte-level tokens
-	tokens := make([]int, len(text))
-	for i, b := range text {
-		tokens[i] = int(b)
+	return t.encode(text, nil)
+}
+
+// encode does the work of Encode, passing skip through to rankTable.apply
+func (t *Tokenizer) encode(text []byte, skip func(rank int) bool) []int {
+	return t.appendEncoded(make([]int, 0, len(text)), t.loadRanks(), text, skip)
+}
+
+// appendEncoded encodes text with ranks onto the end of dst and returns the
+// extended slice, merging each chunk in place in dst's backing array
+func (t *Tokenizer) appendEncoded(dst []int, ranks *rankTable, text []byte, skip func(rank int) bool) []int {
+	text = t.normalize(text)
+
+	if t.Pretokenizer == nil {
+		return t.appendChunk(dst, ranks, text, skip)
 	}
 
-	// Apply each merge in order
-	for _, merge := range t.Merges {
-		tokens = t.applyMerge(tokens, merge.First, merge.Second, merge.Result)
+	for _, chunk := range t.Pretokenizer(text) {
+		dst = t.appendChunk(dst, ranks, chunk, skip)
 	}
+	return dst
+}
 
-	return tokens
+// appendChunk encodes a normalized, already pretokenized chunk and appends
+// its tokens to dst
+func (t *Tokenizer) appendChunk(dst []int, ranks *rankTable, chunk []byte, skip func(rank int) bool) []int {
+	// Start with byte-level tokens
+	start := len(dst)
+	dst = t.appendByteTokens(dst, chunk)
+	// apply compacts in place, so the merged chunk stays at dst[start:]
+	merged := ranks.apply(dst[start:], skip)
+	return dst[:start+len(merged)]
 }
 
 // Decode converts token IDs back into text
+// An ID is invalid if it is negative, not below VocabSize, or an empty slot
+// that no token occupies. Invalid IDs are replaced with UnknownTokenBytes
+// (skipped by default); use DecodeStrict to reject them instead. Every
+// decoding method uses this same definition.
 func (t *Tokenizer) Decode(tokens []int) []byte {
-	result := []byte{}
+	// Size the output up front so long sequences are copied into a single
+	// allocation instead of growing it repeatedly
+	size := 0
 	for _, tokenID := range tokens {
-		if bytes, ok := t.Vocabulary[tokenID]; ok {
+		if bytes, ok := t.renderToken(tokenID); ok {
+			size += len(bytes)
+		} else {
+			size += len(t.UnknownTokenBytes)
+		}
+	}
+
+	result := make([]byte, 0, size)
+	for _, tokenID := range tokens {
+		if bytes, ok := t.renderToken(tokenID); ok {
 			result = append(result, bytes...)
+		} else {
+			result = append(result, t.UnknownTokenBytes...)
 		}
 	}
-	return result
+	return t.unmarkSpaces(result)
+}
+
+// renderToken returns the bytes Decode writes for a single token ID
+// Special tokens render as their name or nothing, depending on
+// RenderSpecialTokens. The second result is false for invalid IDs (see
+// Decode).
+func (t *Tokenizer) renderToken(tokenID int) ([]byte, bool) {
+	if name, ok := t.specialTokens[tokenID]; ok {
+		if t.RenderSpecialTokens {
+			return []byte(name), true
+		}
+		return nil, true
+	}
+	if tokenID < 0 || tokenID >= len(t.Vocabulary) || len(t.Vocabulary[tokenID]) == 0 {
+		return nil, false
+	}
+	return t.Vocabulary[tokenID], true
 }
 
 // countPairs builds initial pair counts from tokens
 // This is only called once at the start of training
-func (t *Tokenizer) countPairs(tokens []int) map[[2]int]int {
+func countPairs(tokens []int) map[[2]int]int {
 	pairCounts := make(map[[2]int]int)
 
 	for i := 0; i < len(tokens)-1; i++ {
+		if tokens[i] == chunkBoundary || tokens[i+1] == chunkBoundary {
+			continue
+		}
 		pair := [2]int{tokens[i], tokens[i+1]}
 		pairCounts[pair]++
 	}
@@ -131,25 +997,96 @@ func (t *Tokenizer) countPairs(tokens []int) map[[2]int]int {
 	return pairCounts
 }
 
-// findMaxPair finds the most frequent pair from the counts map
-func (t *Tokenizer) findMaxPair(pairCounts map[[2]int]int) ([2]int, int) {
-	var mostFrequentPair [2]int
-	maxCount := 0
+// parallelCountThreshold is the token count below which splitting the
+// initial pair count across goroutines isn't worth the merge overhead
+const parallelCountThreshold = 1 << 16
+
+// countPairsParallel is countPairs split across workers goroutines
+// Each worker counts the pairs that start in its chunk of tokens, including
+// the pair that straddles into the next chunk, into a local map; the maps
+// are then summed. Small inputs or a single worker fall back to countPairs.
+func countPairsParallel(tokens []int, workers int) map[[2]int]int {
+	if workers <= 1 || len(tokens) < parallelCountThreshold {
+		return countPairs(tokens)
+	}
+
+	chunkSize := (len(tokens) + workers - 1) / workers
+	partials := make([]map[[2]int]int, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		start := w * chunkSize
+		end := min(start+chunkSize, len(tokens))
+		if start >= end {
+			continue
+		}
+
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			// Extend one token past the chunk to count the boundary pair
+			partials[w] = countPairs(tokens[start:min(end+1, len(tokens))])
+		}(w, start, end)
+	}
+	wg.Wait()
+
+	pairCounts := partials[0]
+	for _, partial := range partials[1:] {
+		for pair, count := range partial {
+			pairCounts[pair] += count
+		}
+	}
+	return pairCounts
+}
+
+// findMaxPair finds the most frequent pair from the counts map by scanning
+// it, and returns the pair with its count
+// Training uses pairQueue instead unless a MergeScorer is set; this linear
+// version is the reference the queue is tested against.
+// If score is non-nil, the pair with the highest score wins instead of the
+// most frequent one. Ties are broken by tieLess (pairLess gives the
+// smallest pair, by First then Second) so that training is deterministic
+// despite Go's random map iteration order. Pairs rejected by allowed (if
+// non-nil) are skipped.
+func findMaxPair(pairCounts map[[2]int]int, allowed func(pair [2]int) bool, score func(pair [2]int, count int) float64, tieLess func(a, b [2]int) bool) ([2]int, int) {
+	var bestPair [2]int
+	bestCount := 0
+	bestScore := 0.0
 
 	for pair, count := range pairCounts {
-		if count > maxCount {
-			maxCount = count
-			mostFrequentPair = pair
+		s := float64(count)
+		if score != nil {
+			s = score(pair, count)
+		}
+		// Only consult the filter for pairs that would beat the current best
+		if bestCount > 0 && (s < bestScore || (s == bestScore && !tieLess(pair, bestPair))) {
+			continue
+		}
+		if allowed != nil && !allowed(pair) {
+			continue
 		}
+		bestPair, bestCount, bestScore = pair, count, s
 	}
 
-	return mostFrequentPair, maxCount
+	return bestPair, bestCount
+}
+
+// pairLess orders pairs by their first token ID, then their second
+func pairLess(a, b [2]int) bool {
+	if a[0] != b[0] {
+		return a[0] < b[0]
+	}
+	return a[1] < b[1]
 }
 
 // applyMergeIncremental replaces all occurrences of (first, second) with merged token
 // and updates the pairCounts map incrementally (the key optimization!)
-func (t *Tokenizer) applyMergeIncremental(tokens []int, first, second, merged int, pairCounts map[[2]int]int) []int {
-	result := []int{}
+// Each affected pair count changes by weight, the weight of this sequence.
+// Pairs whose count increased are added to grown (if non-nil).
+// tokens is rewritten in place and the shortened slice returned, so a merge
+// costs no allocation however long the sequence is.
+func applyMergeIncremental(tokens []int, first, second, merged int, pairCounts map[[2]int]int, weight int, grown map[[2]int]struct{}) []int {
+	result := tokens[:0]
 
 	i := 0
 	for i < len(tokens) {
@@ -158,24 +1095,30 @@ func (t *Tokenizer) applyMergeIncremental(tokens []int, first, second, merged in
 			// Found a merge location - update counts for affected pairs
 
 			// 1. Update left neighbor pair (if exists)
-			if len(result) > 0 {
+			if len(result) > 0 && result[len(result)-1] != chunkBoundary {
 				leftNeighbor := result[len(result)-1]
 				// Decrement old pair (leftNeighbor, first)
-				t.decrementPair(pairCounts, [2]int{leftNeighbor, first})
+				decrementPair(pairCounts, [2]int{leftNeighbor, first}, weight)
 				// Increment new pair (leftNeighbor, merged)
-				pairCounts[[2]int{leftNeighbor, merged}]++
+				pairCounts[[2]int{leftNeighbor, merged}] += weight
+				if grown != nil {
+					grown[[2]int{leftNeighbor, merged}] = struct{}{}
+				}
 			}
 
 			// 2. Decrement the pair we're merging
-			t.decrementPair(pairCounts, [2]int{first, second})
+			decrementPair(pairCounts, [2]int{first, second}, weight)
 
 			// 3. Update right neighbor pair (if exists)
-			if i+2 < len(tokens) {
+			if i+2 < len(tokens) && tokens[i+2] != chunkBoundary {
 				rightNeighbor := tokens[i+2]
 				// Decrement old pair (second, rightNeighbor)
-				t.decrementPair(pairCounts, [2]int{second, rightNeighbor})
+				decrementPair(pairCounts, [2]int{second, rightNeighbor}, weight)
 				// Increment new pair (merged, rightNeighbor)
-				pairCounts[[2]int{merged, rightNeighbor}]++
+				pairCounts[[2]int{merged, rightNeighbor}] += weight
+				if grown != nil {
+					grown[[2]int{merged, rightNeighbor}] = struct{}{}
+				}
 			}
 
 			result = append(result, merged)
@@ -189,30 +1132,10 @@ func (t *Tokenizer) applyMergeIncremental(tokens []int, first, second, merged in
 	return result
 }
 
-// decrementPair decrements a pair count and removes it if it reaches zero
-func (t *Tokenizer) decrementPair(pairCounts map[[2]int]int, pair [2]int) {
-	pairCounts[pair]--
+// decrementPair decreases a pair count by weight and removes it if it reaches zero
+func decrementPair(pairCounts map[[2]int]int, pair [2]int, weight int) {
+	pairCounts[pair] -= weight
 	if pairCounts[pair] <= 0 {
 		delete(pairCounts, pair)
 	}
 }
-
-// applyMerge replaces all occurrences of (first, second) with merged token
-// Used by Encode() which doesn't need incremental counting
-func (t *Tokenizer) applyMerge(tokens []int, first, second, merged int) []int {
-	result := []int{}
-
-	i := 0
-	for i < len(tokens) {
-		// Check if we have a pair to merge
-		if i < len(tokens)-1 && tokens[i] == first && tokens[i+1] == second {
-			result = append(result, merged)
-			i += 2 // Skip both tokens
-		} else {
-			result = append(result, tokens[i])
-			i++
-		}
-	}
-
-	return result
-}
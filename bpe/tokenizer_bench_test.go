@@ -1,6 +1,7 @@
 package bpe
 
 import (
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -58,6 +59,17 @@ func BenchmarkTrain_10KB_Vocab500(b *testing.B) {
 	}
 }
 
+func BenchmarkTrain_10KB_Vocab500_Reset(b *testing.B) {
+	text := generateText(10 * 1024) // 10KB
+	tokenizer := New()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tokenizer.Reset()
+		tokenizer.Train(text, 500)
+	}
+}
+
 func BenchmarkTrain_100KB_Vocab500(b *testing.B) {
 	text := generateText(100 * 1024) // 100KB
 	b.ResetTimer()
@@ -89,6 +101,20 @@ func BenchmarkEncode_1KB(b *testing.B) {
 	}
 }
 
+// encodeBenchTokenizer returns text and a tokenizer with 400 merges learned
+// from it, for comparing Encode against encodeByMergeOrder
+func encodeBenchTokenizer(b *testing.B) (*Tokenizer, []byte) {
+	text := generateVariedText(10 * 1024)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 256+400); err != nil {
+		b.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) != 400 {
+		b.Fatalf("Expected 400 merges, got %d", len(tokenizer.Merges))
+	}
+	return tokenizer, text
+}
+
 func BenchmarkEncode_10KB(b *testing.B) {
 	text := generateText(10 * 1024)
 	tokenizer := New()
@@ -100,6 +126,37 @@ func BenchmarkEncode_10KB(b *testing.B) {
 	}
 }
 
+func BenchmarkEncode_10KB_400Merges(b *testing.B) {
+	tokenizer, text := encodeBenchTokenizer(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.Encode(text)
+	}
+}
+
+func BenchmarkEncodeAppend_10KB(b *testing.B) {
+	text := generateText(10 * 1024)
+	tokenizer := New()
+	tokenizer.Train(text, 400)
+	buf := make([]int, 0, len(text))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf = tokenizer.EncodeAppend(buf[:0], text)
+	}
+}
+
+func BenchmarkEncodeByMergeOrder_10KB_400Merges(b *testing.B) {
+	tokenizer, text := encodeBenchTokenizer(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encodeByMergeOrder(tokenizer, text)
+	}
+}
+
 func BenchmarkDecode_1KB(b *testing.B) {
 	text := generateText(1024)
 	tokenizer := New()
@@ -111,3 +168,150 @@ func BenchmarkDecode_1KB(b *testing.B) {
 		tokenizer.Decode(tokens)
 	}
 }
+
+func BenchmarkDecode_100KTokens(b *testing.B) {
+	tokenizer := New()
+	tokenizer.Train(generateVariedText(100*1024), 2000)
+	tokens := tokenizer.Encode(generateVariedText(400 * 1024))[:100000]
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.Decode(tokens)
+	}
+}
+
+func BenchmarkDecode_1MTokens(b *testing.B) {
+	tokenizer := New()
+	tokenizer.Train(generateVariedText(100*1024), 2000)
+	chunk := tokenizer.Encode(generateVariedText(400 * 1024))[:100000]
+	tokens := make([]int, 0, 10*len(chunk))
+	for range 10 {
+		tokens = append(tokens, chunk...)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.Decode(tokens)
+	}
+}
+
+func generateDocuments(count int) [][]byte {
+	docs := make([][]byte, count)
+	for i := range docs {
+		docs[i] = generateText(200 + i%300)
+	}
+	return docs
+}
+
+func BenchmarkEncodeBatch_1000Docs_Serial(b *testing.B) {
+	tokenizer := New()
+	tokenizer.Train(generateText(10*1024), 400)
+	docs := generateDocuments(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, doc := range docs {
+			tokenizer.Encode(doc)
+		}
+	}
+}
+
+func BenchmarkEncodeBatch_1000Docs_4Workers(b *testing.B) {
+	tokenizer := New()
+	tokenizer.Train(generateText(10*1024), 400)
+	docs := generateDocuments(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.EncodeBatch(docs, 4)
+	}
+}
+
+func BenchmarkCountTokens_10KB(b *testing.B) {
+	text := generateText(10 * 1024)
+	tokenizer := New()
+	tokenizer.Train(text, 400)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tokenizer.CountTokens(text)
+	}
+}
+
+func BenchmarkCountPairs_1MB_Serial(b *testing.B) {
+	tokenizer := New()
+	tokens := tokenizer.trainingTokens(generateText(1024 * 1024))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		countPairs(tokens)
+	}
+}
+
+func BenchmarkCountPairs_1MB_Parallel(b *testing.B) {
+	tokenizer := New()
+	tokens := tokenizer.trainingTokens(generateText(1024 * 1024))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		countPairsParallel(tokens, runtime.GOMAXPROCS(0))
+	}
+}
+
+// generateVariedText builds text from a large pseudo-random word list so
+// that training keeps finding new pairs well past a few hundred merges
+func generateVariedText(size int) []byte {
+	const letters = "etaoinshrdlcumwfgypbvkjxqz"
+	seed := uint32(1)
+	next := func(n int) int {
+		seed = seed*1664525 + 1013904223
+		return int(seed>>8) % n
+	}
+
+	words := make([]string, 2000)
+	for i := range words {
+		word := make([]byte, 2+next(8))
+		for j := range word {
+			// Square the draw to favor common letters
+			r := next(len(letters))
+			word[j] = letters[r*r/len(letters)]
+		}
+		words[i] = string(word)
+	}
+
+	var builder strings.Builder
+	for builder.Len() < size {
+		// Square the draw to favor a small set of frequent words
+		r := next(len(words))
+		builder.WriteString(words[r*r/len(words)])
+		builder.WriteByte(' ')
+	}
+
+	return []byte(builder.String()[:size])
+}
+
+func BenchmarkTrain_100KB_Vocab5000(b *testing.B) {
+	text := generateVariedText(100 * 1024) // 100KB
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tokenizer := New()
+		tokenizer.Train(text, 5000)
+	}
+}
+
+// BenchmarkTrainLinearScan_100KB_Vocab5000 is BenchmarkTrain_100KB_Vocab5000
+// with a full scan of the pair counts for every merge instead of the heap
+// Train uses, to show what the heap saves once most pairs are rare.
+func BenchmarkTrainLinearScan_100KB_Vocab5000(b *testing.B) {
+	text := generateVariedText(100 * 1024) // 100KB
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tokenizer := New()
+		trainByLinearScan(tokenizer, text, TrainOptions{TargetVocabSize: 5000})
+	}
+}
@@ -2,6 +2,13 @@ package bpe
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"math"
+	"math/bits"
+	"runtime"
+	"slices"
+	"sync"
 	"testing"
 )
 
@@ -118,6 +125,77 @@ func TestTrainAndEncodeNew(t *testing.T) {
 	}
 }
 
+func TestTrainRecordsMergeCounts(t *testing.T) {
+	text := generateText(16 * 1024)
+	tokenizer := New()
+	if err := tokenizer.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// The first merge is the most frequent byte pair in the text
+	_, want := findMaxPair(countPairs(New().trainingTokens(text)), nil, nil, pairLess)
+	if got := tokenizer.Merges[0].Count; got != want {
+		t.Errorf("Expected the first merge to have count %d, got %d", want, got)
+	}
+	for i := 1; i < len(tokenizer.Merges); i++ {
+		prev, merge := tokenizer.Merges[i-1], tokenizer.Merges[i]
+		if merge.Count <= 0 {
+			t.Errorf("Merge %d has count %d", i, merge.Count)
+		}
+		if merge.Count > prev.Count {
+			t.Errorf("Merge %d count %d is above merge %d count %d", i, merge.Count, i-1, prev.Count)
+		}
+	}
+}
+
+func TestTrainLongSingleByteRun(t *testing.T) {
+	const n = 100_000
+	text := bytes.Repeat([]byte{'a'}, n)
+
+	tokenizer := New()
+	if err := tokenizer.Train(text, 1000); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Doubling merges take the run down to a few tokens, which then join
+	// into one; there's nothing left to learn after that
+	maxMerges := 2 * bits.Len(n)
+	if len(tokenizer.Merges) < bits.Len(n)-1 || len(tokenizer.Merges) > maxMerges {
+		t.Errorf("Expected between %d and %d merges, got %d", bits.Len(n)-1, maxMerges, len(tokenizer.Merges))
+	}
+	if tokens := tokenizer.Encode(text); len(tokens) != 1 {
+		t.Errorf("Expected the run to encode as one token, got %d", len(tokens))
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+}
+
+func TestTrainLongSingleByteRunAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are unreliable under the race detector")
+	}
+	const n = 100_000
+	text := bytes.Repeat([]byte{'a'}, n)
+
+	// Merges rewrite the sequence in place rather than copying it each
+	// time. The smallest of a few runs keeps allocations the runtime makes
+	// in the background out of the measurement.
+	least := uint64(math.MaxUint64)
+	for range 3 {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if err := New().Train(text, 1000); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		runtime.ReadMemStats(&after)
+		least = min(least, after.TotalAlloc-before.TotalAlloc)
+	}
+	if least > 40*n {
+		t.Errorf("Training allocated %d bytes, expected at most %d", least, 40*n)
+	}
+}
+
 func TestTargetVocabSizeTooSmall(t *testing.T) {
 	tokenizer := New()
 	text := []byte("test")
@@ -228,3 +306,1098 @@ func TestMergeOrder(t *testing.T) {
 			tokenizer.Merges[0].First, tokenizer.Merges[0].Second)
 	}
 }
+
+// encodeByMergeOrder is the original Encode: one full pass per merge, in
+// the order the merges were learned. Encode must always agree with it.
+func encodeByMergeOrder(t *Tokenizer, text []byte) []int {
+	tokens := make([]int, len(text))
+	for i, b := range text {
+		tokens[i] = int(b)
+	}
+
+	for _, merge := range t.Merges {
+		result := []int{}
+		i := 0
+		for i < len(tokens) {
+			if i < len(tokens)-1 && tokens[i] == merge.First && tokens[i+1] == merge.Second {
+				result = append(result, merge.Result)
+				i += 2
+			} else {
+				result = append(result, tokens[i])
+				i++
+			}
+		}
+		tokens = result
+	}
+
+	return tokens
+}
+
+func equalTokens(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEncodeMatchesMergeOrder(t *testing.T) {
+	corpora := [][]byte{
+		[]byte("aaabdaaabac"),
+		[]byte("low lower lowest"),
+		[]byte("ababababab"),
+		[]byte("aaaaaaaaaaaaaaaaaaaaaaaaa"),
+		generateText(4096),
+	}
+
+	for _, corpus := range corpora {
+		tokenizer := New()
+		if err := tokenizer.Train(corpus, 400); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		inputs := [][]byte{corpus, []byte(""), []byte("a"), []byte("the lowest aaaa abab"), generateText(777)}
+		for _, input := range inputs {
+			expected := encodeByMergeOrder(tokenizer, input)
+			got := tokenizer.Encode(input)
+			if !equalTokens(expected, got) {
+				t.Errorf("Encode(%q) = %v, expected %v", input, got, expected)
+			}
+		}
+	}
+}
+
+func TestEncodeIgnoresLowerRankPairsFormedLater(t *testing.T) {
+	// Hand-built merges where an earlier rule refers to a later result:
+	// a sequential pass never revisits rank 0 after rank 1 creates "bc"
+	tokenizer := New()
+	tokenizer.Merges = []Merge{
+		{First: 'a', Second: 257, Result: 256},
+		{First: 'b', Second: 'c', Result: 257},
+	}
+	tokenizer.Vocabulary = append(tokenizer.Vocabulary, []byte("abc"), []byte("bc"))
+	tokenizer.VocabSize = 258
+
+	text := []byte("abc")
+	expected := encodeByMergeOrder(tokenizer, text)
+	got := tokenizer.Encode(text)
+	if !equalTokens(expected, got) {
+		t.Errorf("Encode(%q) = %v, expected %v", text, got, expected)
+	}
+}
+
+func TestEncodeSeesMergesAddedAfterEncoding(t *testing.T) {
+	tokenizer := New()
+	text := []byte("aaabdaaabac")
+
+	// Populate the rank cache with the untrained tokenizer
+	if len(tokenizer.Encode(text)) != len(text) {
+		t.Fatalf("Expected byte-level encoding before training")
+	}
+
+	if err := tokenizer.Train(text, 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	expected := encodeByMergeOrder(tokenizer, text)
+	got := tokenizer.Encode(text)
+	if !equalTokens(expected, got) {
+		t.Errorf("Encode after training = %v, expected %v", got, expected)
+	}
+}
+
+func TestTrainWithOptionsMinFrequency(t *testing.T) {
+	tokenizer := New()
+	// "ab" occurs 4 times, every other pair at most 3 times
+	text := []byte("ab ab ab ab")
+
+	learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, MinFrequency: 4})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Only "ab" meets the threshold; after merging it, " " + "ab" occurs 3 times
+	if learned != 1 {
+		t.Errorf("Expected 1 merge, got %d", learned)
+	}
+	if len(tokenizer.Merges) != learned {
+		t.Errorf("Expected %d recorded merges, got %d", learned, len(tokenizer.Merges))
+	}
+	if tokenizer.VocabSize != 257 {
+		t.Errorf("Expected vocab size 257, got %d", tokenizer.VocabSize)
+	}
+}
+
+func TestTrainWithOptionsNoPairMeetsThreshold(t *testing.T) {
+	tokenizer := New()
+	text := []byte("abcdefg")
+
+	learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, MinFrequency: 2})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if learned != 0 {
+		t.Errorf("Expected training to stop before any merge, got %d merges", learned)
+	}
+	if tokenizer.VocabSize != 256 {
+		t.Errorf("Expected vocab size 256, got %d", tokenizer.VocabSize)
+	}
+}
+
+func TestTrainWithOptionsReportsLearnedMerges(t *testing.T) {
+	tokenizer := New()
+
+	learned, err := tokenizer.TrainWithOptions([]byte("aaabdaaabac"), TrainOptions{TargetVocabSize: 260})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if learned != 4 {
+		t.Errorf("Expected 4 merges, got %d", learned)
+	}
+
+	if _, err := tokenizer.TrainWithOptions([]byte("abc"), TrainOptions{TargetVocabSize: 300, MinFrequency: -1}); err == nil {
+		t.Error("Expected error for negative minimum frequency")
+	}
+}
+
+func TestTokenForBytes(t *testing.T) {
+	tokenizer := New()
+
+	// Every single byte has a base token
+	id, ok := tokenizer.TokenForBytes([]byte("a"))
+	if !ok || id != 97 {
+		t.Errorf("Expected token 97 for \"a\", got %d (found=%v)", id, ok)
+	}
+
+	if _, ok := tokenizer.TokenForBytes([]byte("aa")); ok {
+		t.Error("Expected no token for \"aa\" before training")
+	}
+
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Merges learned during training are indexed as they are added
+	id, ok = tokenizer.TokenForBytes([]byte("aa"))
+	if !ok || id != 256 {
+		t.Errorf("Expected token 256 for \"aa\", got %d (found=%v)", id, ok)
+	}
+	id, ok = tokenizer.TokenForBytes([]byte("aaa"))
+	if !ok || id != 257 {
+		t.Errorf("Expected token 257 for \"aaa\", got %d (found=%v)", id, ok)
+	}
+
+	if _, ok := tokenizer.TokenForBytes([]byte("aaaa")); ok {
+		t.Error("Expected no token for \"aaaa\"")
+	}
+	if _, ok := tokenizer.TokenForBytes(nil); ok {
+		t.Error("Expected no token for empty bytes")
+	}
+}
+
+func TestContinueTraining(t *testing.T) {
+	tokenizer := New()
+	text := []byte("aaabdaaabac aaabdaaabac low lower lowest")
+
+	if err := tokenizer.Train(text, 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	original := append([]Merge{}, tokenizer.Merges...)
+
+	moreText := []byte("newer newest aaabdaaabac")
+	if err := tokenizer.Train(moreText, 264); err != nil {
+		t.Fatalf("Continued training failed: %v", err)
+	}
+
+	if len(tokenizer.Merges) != 8 {
+		t.Fatalf("Expected 8 merges, got %d", len(tokenizer.Merges))
+	}
+	if tokenizer.VocabSize != 264 {
+		t.Errorf("Expected vocab size 264, got %d", tokenizer.VocabSize)
+	}
+
+	// The original merges are preserved as the first entries
+	for i, merge := range original {
+		if tokenizer.Merges[i] != merge {
+			t.Errorf("Merge %d changed: expected %+v, got %+v", i, merge, tokenizer.Merges[i])
+		}
+	}
+
+	// Continued training must not relearn an existing pair
+	seen := make(map[[2]int]bool)
+	for _, merge := range tokenizer.Merges {
+		pair := [2]int{merge.First, merge.Second}
+		if seen[pair] {
+			t.Errorf("Pair %v was learned twice", pair)
+		}
+		seen[pair] = true
+	}
+
+	for _, input := range [][]byte{text, moreText} {
+		if decoded := tokenizer.Decode(tokenizer.Encode(input)); !bytes.Equal(decoded, input) {
+			t.Errorf("Decoded text doesn't match original.\nExpected: %s\nGot: %s", input, decoded)
+		}
+	}
+}
+
+func TestContinueTrainingTargetAlreadyReached(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Asking for a vocabulary we already have learns nothing
+	learned, err := tokenizer.TrainWithOptions([]byte("aaabdaaabac"), TrainOptions{TargetVocabSize: 258})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if learned != 0 || len(tokenizer.Merges) != 4 {
+		t.Errorf("Expected no new merges, learned %d (total %d)", learned, len(tokenizer.Merges))
+	}
+}
+
+// TestConcurrentEncodeDecode is most useful under `go test -race`
+func TestConcurrentEncodeDecode(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train(generateText(8192), 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Force the rank table to be rebuilt by the goroutines themselves
+	tokenizer.ranks.Store(nil)
+
+	inputs := make([][]byte, 100)
+	expected := make([][]int, len(inputs))
+	for i := range inputs {
+		inputs[i] = generateText(50 + i*10)
+		expected[i] = encodeByMergeOrder(tokenizer, inputs[i])
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, len(inputs))
+	for i := range inputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				tokens := tokenizer.Encode(inputs[i])
+				if !equalTokens(tokens, expected[i]) {
+					errs <- "Encode result differs under concurrency"
+					return
+				}
+				if !bytes.Equal(tokenizer.Decode(tokens), inputs[i]) {
+					errs <- "Decode result differs under concurrency"
+					return
+				}
+				if tokenizer.CountTokens(inputs[i]) != len(tokens) {
+					errs <- "CountTokens result differs under concurrency"
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestTrainWithOptionsMaxTokenBytes(t *testing.T) {
+	tokenizer := New()
+	text := []byte("abcabcabcabcabcabcabcabcabcabc xyzxyzxyzxyzxyzxyz")
+
+	learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, MaxTokenBytes: 3})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if learned == 0 {
+		t.Fatal("Expected some merges within the byte cap")
+	}
+
+	for id, tokenBytes := range tokenizer.Vocabulary {
+		if len(tokenBytes) > 3 {
+			t.Errorf("Token %d (%q) exceeds 3 bytes", id, tokenBytes)
+		}
+	}
+
+	// Without the cap the same corpus produces longer tokens
+	uncapped := New()
+	if err := uncapped.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	longest := 0
+	for _, tokenBytes := range uncapped.Vocabulary {
+		longest = max(longest, len(tokenBytes))
+	}
+	if longest <= 3 {
+		t.Errorf("Expected uncapped training to learn tokens longer than 3 bytes, longest is %d", longest)
+	}
+
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Errorf("Decoded text doesn't match original.\nExpected: %s\nGot: %s", text, decoded)
+	}
+
+	if _, err := New().TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, MaxTokenBytes: -1}); err == nil {
+		t.Error("Expected error for negative MaxTokenBytes")
+	}
+}
+
+func TestCountPairsParallelMatchesSerial(t *testing.T) {
+	tokenizer := New()
+	text := generateText(3*parallelCountThreshold + 17)
+
+	// Include chunk boundaries, which must never be counted
+	tokens := tokenizer.trainingTokens(text)
+	for i := 1000; i < len(tokens); i += 4099 {
+		tokens[i] = chunkBoundary
+	}
+
+	expected := countPairs(tokens)
+	for _, workers := range []int{1, 2, 3, 7, 16} {
+		got := countPairsParallel(tokens, workers)
+		if len(got) != len(expected) {
+			t.Fatalf("workers=%d: expected %d pairs, got %d", workers, len(expected), len(got))
+		}
+		for pair, count := range expected {
+			if got[pair] != count {
+				t.Errorf("workers=%d: pair %v counted %d times, expected %d", workers, pair, got[pair], count)
+			}
+		}
+	}
+}
+
+func TestResetMatchesNew(t *testing.T) {
+	text := []byte("low lower lowest newer newest")
+
+	reused := New()
+	reused.AddSpecialToken("<eos>")
+	if err := reused.Train([]byte("aaabdaaabac"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	reused.Reset()
+
+	if reused.VocabSize != 256 || len(reused.Vocabulary) != 256 || len(reused.Merges) != 0 {
+		t.Fatalf("Expected a fresh tokenizer after Reset, got VocabSize %d, %d entries, %d merges",
+			reused.VocabSize, len(reused.Vocabulary), len(reused.Merges))
+	}
+	if len(reused.SpecialTokens()) != 0 {
+		t.Errorf("Expected special tokens to be cleared, got %v", reused.SpecialTokens())
+	}
+
+	fresh := New()
+	for _, tokenizer := range []*Tokenizer{reused, fresh} {
+		if err := tokenizer.Train(text, 280); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+	}
+
+	if !equalMerges(reused.Merges, fresh.Merges) {
+		t.Error("Training after Reset learned different merges than training after New")
+	}
+	for id, expected := range fresh.Vocabulary {
+		if !bytes.Equal(reused.Vocabulary[id], expected) {
+			t.Errorf("Vocabulary entry %d: expected %q, got %q", id, expected, reused.Vocabulary[id])
+		}
+	}
+	if !equalTokens(reused.Encode(text), fresh.Encode(text)) {
+		t.Error("Encoding after Reset differs from encoding after New")
+	}
+	if id, ok := reused.TokenForBytes(fresh.Vocabulary[fresh.VocabSize-1]); !ok || id != fresh.VocabSize-1 {
+		t.Errorf("Expected reverse index to find token %d, got %d (found=%v)", fresh.VocabSize-1, id, ok)
+	}
+}
+
+func TestResetKeepsConfiguration(t *testing.T) {
+	tokenizer := New()
+	tokenizer.Pretokenizer = GPT2Pretokenizer
+	tokenizer.SpaceMarker = SentencePieceMarker
+	tokenizer.RenderSpecialTokens = true
+	tokenizer.UnknownTokenBytes = []byte("?")
+	tokenizer.OutOfAlphabet = ReplaceByte
+	tokenizer.ReplacementToken = 'x'
+	if err := tokenizer.Train([]byte("low lower lowest"), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer.Reset()
+
+	if tokenizer.Pretokenizer == nil || tokenizer.SpaceMarker != SentencePieceMarker ||
+		!tokenizer.RenderSpecialTokens || string(tokenizer.UnknownTokenBytes) != "?" ||
+		tokenizer.OutOfAlphabet != ReplaceByte || tokenizer.ReplacementToken != 'x' {
+		t.Errorf("Expected Reset to keep the configuration, got %+v", tokenizer)
+	}
+}
+
+func TestTrainValidateUTF8(t *testing.T) {
+	// "é" is 0xC3 0xA9; the second copy is cut short by a space
+	text := []byte("caf\xc3\xa9 caf\xc3 caf\xc3\xa9")
+
+	tokenizer := New()
+	_, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 260, ValidateUTF8: true})
+	var utf8Err *InvalidUTF8Error
+	if !errors.As(err, &utf8Err) {
+		t.Fatalf("Expected an InvalidUTF8Error, got %v", err)
+	}
+	if utf8Err.Offset != 9 || utf8Err.Count != 1 {
+		t.Errorf("Expected 1 invalid sequence at byte 9, got %d at byte %d", utf8Err.Count, utf8Err.Offset)
+	}
+	if len(tokenizer.Merges) != 0 {
+		t.Errorf("Expected nothing to be learned, got %d merges", len(tokenizer.Merges))
+	}
+
+	// Without the flag the same text trains normally
+	if _, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 260}); err != nil {
+		t.Errorf("Expected training without ValidateUTF8 to succeed, got %v", err)
+	}
+	if _, err := New().TrainWithOptions([]byte("café"), TrainOptions{TargetVocabSize: 260, ValidateUTF8: true}); err != nil {
+		t.Errorf("Expected valid UTF-8 to pass, got %v", err)
+	}
+}
+
+func TestEncodeDecodeString(t *testing.T) {
+	tokenizer := New()
+	text := "Hello, World!"
+
+	// Without training, each byte should be its own token
+	if tokens := tokenizer.EncodeString(text); len(tokens) != len(text) {
+		t.Errorf("Expected %d tokens, got %d", len(text), len(tokens))
+	}
+
+	trainText := "low lower lowest"
+	if err := tokenizer.Train([]byte(trainText), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokens := tokenizer.EncodeString(trainText)
+	if !equalTokens(tokens, tokenizer.Encode([]byte(trainText))) {
+		t.Errorf("EncodeString differs from Encode")
+	}
+	if len(tokens) >= len(trainText) {
+		t.Errorf("Expected fewer tokens than bytes after training. Bytes: %d, Tokens: %d", len(trainText), len(tokens))
+	}
+	if decoded := tokenizer.DecodeString(tokens); decoded != trainText {
+		t.Errorf("Decoded text doesn't match original.\nExpected: %s\nGot: %s", trainText, decoded)
+	}
+	if decoded := tokenizer.DecodeString(nil); decoded != "" {
+		t.Errorf("Expected empty string, got %q", decoded)
+	}
+}
+
+func TestTrainProgress(t *testing.T) {
+	tokenizer := New()
+	text := generateText(10 * 1024)
+
+	calls := 0
+	opts := TrainOptions{
+		TargetVocabSize: 300,
+		Progress: func(merged, target int) {
+			calls++
+			if merged != calls {
+				t.Errorf("Call %d reported %d merges", calls, merged)
+			}
+			if target != 300-256 {
+				t.Errorf("Expected target %d, got %d", 300-256, target)
+			}
+		},
+	}
+	learned, err := tokenizer.TrainWithOptions(text, opts)
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if calls != 300-256 || learned != calls {
+		t.Errorf("Expected %d progress calls, got %d (learned %d merges)", 300-256, calls, learned)
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	text := []byte("low lower lowest newer newest")
+	original := New()
+	eos := original.AddSpecialToken("<eos>")
+	if err := original.Train(text, 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	merges := slices.Clone(original.Merges)
+	encoded := original.Encode(text)
+
+	clone := original.Clone()
+	if !equalMerges(clone.Merges, original.Merges) || !equalTokens(clone.Encode(text), encoded) {
+		t.Fatal("Expected the clone to encode like the original")
+	}
+
+	// Mutate the clone every way we can
+	clone.Merges[0].Second = 'z'
+	clone.Vocabulary[257][0] = 'Z'
+	clone.AddSpecialToken("<pad>")
+	if err := clone.Train([]byte("xyzxyzxyz"), 280); err != nil {
+		t.Fatalf("Training clone failed: %v", err)
+	}
+
+	if !equalMerges(original.Merges, merges) {
+		t.Error("Changing the clone's merges changed the original")
+	}
+	if original.VocabSize != 270 || len(original.Vocabulary) != 270 {
+		t.Errorf("Expected original to keep 270 tokens, got VocabSize %d with %d entries", original.VocabSize, len(original.Vocabulary))
+	}
+	if !equalTokens(original.Encode(text), encoded) {
+		t.Error("Original encodes differently after the clone was modified")
+	}
+	if specials := original.SpecialTokens(); len(specials) != 1 || specials["<eos>"] != eos {
+		t.Errorf("Expected original to keep only <eos>, got %v", specials)
+	}
+	if err := original.Validate(); err != nil {
+		t.Errorf("Original no longer validates: %v", err)
+	}
+}
+
+func TestTrainMinCompressionGain(t *testing.T) {
+	text := generateVariedText(20 * 1024)
+
+	full := New()
+	fullLearned, err := full.TrainWithOptions(text, TrainOptions{TargetVocabSize: 3000})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokenizer := New()
+	learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 3000, MinCompressionGain: 0.002})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if learned == 0 || learned >= fullLearned {
+		t.Fatalf("Expected training to stop early, learned %d merges (%d without the option)", learned, fullLearned)
+	}
+	if !equalMerges(tokenizer.Merges, full.Merges[:learned]) {
+		t.Error("Expected the merges learned to be a prefix of unrestricted training")
+	}
+
+	// The last merge is the first to fall short of the requested gain
+	before := len(full.EncodeWithMaxMerges(text, learned-1))
+	after := len(full.EncodeWithMaxMerges(text, learned))
+	if gain := float64(before-after) / float64(before); gain >= 0.002 {
+		t.Errorf("Expected the final merge to gain less than 0.2%%, got %.4f", gain)
+	}
+
+	for _, gain := range []float64{-0.1, 1} {
+		if _, err := New().TrainWithOptions(text, TrainOptions{TargetVocabSize: 300, MinCompressionGain: gain}); err == nil {
+			t.Errorf("Expected error for MinCompressionGain %v", gain)
+		}
+	}
+}
+
+func TestTrainCompressionCurve(t *testing.T) {
+	text := generateVariedText(20 * 1024)
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		var curve []int
+		learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 600, CompressionCurve: &curve})
+		if err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		if len(curve) != learned {
+			t.Fatalf("Expected %d curve entries, got %d", learned, len(curve))
+		}
+		previous := len(text)
+		for i, count := range curve {
+			if count > previous {
+				t.Fatalf("Curve goes up at merge %d: %d after %d", i, count, previous)
+			}
+			previous = count
+		}
+		if last, encoded := curve[len(curve)-1], len(tokenizer.Encode(text)); last != encoded {
+			t.Errorf("Expected the last entry to be %d, got %d", encoded, last)
+		}
+		if mid := len(curve) / 2; curve[mid] != len(tokenizer.EncodeWithMaxMerges(text, mid+1)) {
+			t.Errorf("Expected entry %d to be %d, got %d", mid, len(tokenizer.EncodeWithMaxMerges(text, mid+1)), curve[mid])
+		}
+	}
+}
+
+func TestTrainTokensMatchesTrain(t *testing.T) {
+	text := []byte("low lower lowest newer newest widest")
+	byteTokens := make([]int, len(text))
+	for i, b := range text {
+		byteTokens[i] = int(b)
+	}
+
+	expected := New()
+	if err := expected.Train(text, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	tokenizer := New()
+	if err := tokenizer.TrainTokens(byteTokens, 280); err != nil {
+		t.Fatalf("TrainTokens failed: %v", err)
+	}
+	if !equalMerges(tokenizer.Merges, expected.Merges) {
+		t.Errorf("TrainTokens learned different merges than Train")
+	}
+
+	// Resuming from an encoding matches continued training on the text
+	resumed := New()
+	if err := resumed.Train(text, 265); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	encoded := resumed.Encode(text)
+	if err := resumed.TrainTokens(encoded, 280); err != nil {
+		t.Fatalf("TrainTokens failed: %v", err)
+	}
+	if !equalMerges(resumed.Merges, expected.Merges) {
+		t.Errorf("Resuming from an encoding learned different merges than training straight through")
+	}
+}
+
+func TestTrainTokensValidatesIDs(t *testing.T) {
+	tokenizer := New()
+	for _, tokens := range [][]int{{'a', 256}, {-1, 'a'}} {
+		if err := tokenizer.TrainTokens(tokens, 260); err == nil {
+			t.Errorf("Expected error for out-of-vocabulary tokens %v", tokens)
+		}
+	}
+	if len(tokenizer.Merges) != 0 {
+		t.Errorf("Expected nothing to be learned, got %d merges", len(tokenizer.Merges))
+	}
+
+	// A special token separates documents; no merge crosses it
+	sep := tokenizer.AddSpecialToken("<sep>")
+	tokens := []int{'a', 'b', sep, 'a', 'b', sep, 'b', 'a'}
+	if err := tokenizer.TrainTokens(tokens, 259); err != nil {
+		t.Fatalf("TrainTokens failed: %v", err)
+	}
+	for _, merge := range tokenizer.Merges {
+		if merge.First == sep || merge.Second == sep {
+			t.Errorf("Merge %+v uses the separator", merge)
+		}
+	}
+	if tokens[2] != sep {
+		t.Error("TrainTokens modified the caller's slice")
+	}
+}
+
+func TestTrainEmptyTextIsNoOp(t *testing.T) {
+	tokenizer := New()
+	for _, text := range [][]byte{nil, {}, []byte("a")} {
+		learned, err := tokenizer.TrainWithOptions(text, TrainOptions{TargetVocabSize: 300})
+		if err != nil || learned != 0 {
+			t.Errorf("Training on %q: expected a no-op, got %d merges (err=%v)", text, learned, err)
+		}
+	}
+	if tokenizer.VocabSize != 256 || len(tokenizer.Vocabulary) != 256 || len(tokenizer.Merges) != 0 {
+		t.Errorf("Expected an untouched vocabulary, got VocabSize %d with %d merges", tokenizer.VocabSize, len(tokenizer.Merges))
+	}
+
+	// A trained tokenizer keeps its merges
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if err := tokenizer.Train([]byte(""), 300); err != nil {
+		t.Errorf("Expected no error for empty text, got %v", err)
+	}
+	if tokenizer.VocabSize != 260 || len(tokenizer.Merges) != 4 {
+		t.Errorf("Expected 260 tokens and 4 merges to remain, got %d and %d", tokenizer.VocabSize, len(tokenizer.Merges))
+	}
+
+	// Option errors take precedence over the empty-text no-op
+	if err := New().Train([]byte(""), 100); err == nil {
+		t.Error("Expected error for invalid vocab size even with empty text")
+	}
+}
+
+func FuzzEncodeDecode(f *testing.F) {
+	corpus := append(generateText(4096), "\x00\x00\xff\xfe aaaaaaaaaaaaaaaa \xe2\x82\xac\xe2\x82"...)
+	plain := New()
+	if err := plain.Train(corpus, 400); err != nil {
+		f.Fatalf("Training failed: %v", err)
+	}
+	pretokenized := New()
+	pretokenized.Pretokenizer = GPT2Pretokenizer
+	if err := pretokenized.Train(corpus, 400); err != nil {
+		f.Fatalf("Training failed: %v", err)
+	}
+
+	for _, seed := range []string{
+		"",
+		"the quick brown fox",
+		"\x00\x00\x00",
+		"\xff\xfe\xfd\x80",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"\xe2\x82\xac \xe2\x82 \x82\xac",
+		"  \t\n\n  's 've 123 !!",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, text []byte) {
+		for _, tokenizer := range []*Tokenizer{plain, pretokenized} {
+			tokens := tokenizer.Encode(text)
+			if decoded := tokenizer.Decode(tokens); !bytes.Equal(decoded, text) {
+				t.Fatalf("Round-trip failed (pretokenizer=%v): %q -> %v -> %q",
+					tokenizer.Pretokenizer != nil, text, tokens, decoded)
+			}
+			if count := tokenizer.CountTokens(text); count != len(tokens) {
+				t.Fatalf("CountTokens = %d, Encode produced %d tokens for %q", count, len(tokens), text)
+			}
+		}
+	})
+}
+
+func TestTrainToRatio(t *testing.T) {
+	text := generateText(10 * 1024)
+
+	tokenizer := New()
+	if err := tokenizer.TrainToRatio(text, 2.5, 1000); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	ratio := tokenizer.Stats(text).CompressionRatio
+	if ratio < 2.5 {
+		t.Fatalf("Expected a compression ratio of at least 2.5, got %.3f", ratio)
+	}
+	if tokenizer.VocabSize >= 1000 {
+		t.Fatalf("Expected to stop before the vocabulary cap, got %d tokens", tokenizer.VocabSize)
+	}
+	// It stops at the first merge that reaches the target
+	fewer := tokenizer.EncodeWithMaxMerges(text, len(tokenizer.Merges)-1)
+	if previous := float64(len(text)) / float64(len(fewer)); previous >= 2.5 {
+		t.Errorf("Expected one merge fewer to fall short of 2.5, got %.3f", previous)
+	}
+
+	// An unreachable target stops at maxVocab
+	capped := New()
+	if err := capped.TrainToRatio(text, 1000, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if capped.VocabSize != 300 {
+		t.Errorf("Expected training to stop at maxVocab 300, got %d", capped.VocabSize)
+	}
+
+	if err := New().TrainToRatio(text, 0, 300); err == nil {
+		t.Error("Expected error for a non-positive target ratio")
+	}
+}
+
+func TestTrainToRatioSpaceMarker(t *testing.T) {
+	text := generateText(10 * 1024)
+
+	tokenizer := New()
+	tokenizer.SpaceMarker = SentencePieceMarker
+	if err := tokenizer.TrainToRatio(text, 3.0, 1000); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	// Markers take three bytes but replace one, so the ratio is measured
+	// on the input
+	if ratio := tokenizer.Stats(text).CompressionRatio; ratio < 3.0 {
+		t.Errorf("Expected a compression ratio of at least 3.0, got %.3f", ratio)
+	}
+	if tokenizer.VocabSize >= 1000 {
+		t.Errorf("Expected to stop before the vocabulary cap, got %d tokens", tokenizer.VocabSize)
+	}
+}
+
+func TestTrainWithOptionsForbiddenPairs(t *testing.T) {
+	text := []byte("aaabdaaabac")
+
+	unrestricted := New()
+	if err := unrestricted.Train(text, 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	top := [2]int{unrestricted.Merges[0].First, unrestricted.Merges[0].Second}
+
+	tokenizer := New()
+	opts := TrainOptions{TargetVocabSize: 260, ForbiddenPairs: map[[2]int]bool{top: true}}
+	if _, err := tokenizer.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) == 0 {
+		t.Fatal("Expected other pairs to be merged")
+	}
+	if first := [2]int{tokenizer.Merges[0].First, tokenizer.Merges[0].Second}; first == top {
+		t.Errorf("Expected a different first merge than the forbidden %v", top)
+	}
+	for _, merge := range tokenizer.Merges {
+		if [2]int{merge.First, merge.Second} == top {
+			t.Errorf("Forbidden pair %v was merged", top)
+		}
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Errorf("Decoded text doesn't match original.\nExpected: %s\nGot: %s", text, decoded)
+	}
+}
+
+func TestTrainWithResultReportsShortfall(t *testing.T) {
+	tokenizer := New()
+	result, err := tokenizer.TrainWithResult([]byte("aaabdaaabac"), TrainOptions{TargetVocabSize: 100000})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if result.Target != 100000 {
+		t.Errorf("Expected target 100000, got %d", result.Target)
+	}
+	if result.Achieved >= result.Target || result.Reached() {
+		t.Errorf("Expected training to fall short of the target, got %+v", result)
+	}
+	if result.Achieved != tokenizer.VocabSize || result.Merges != len(tokenizer.Merges) {
+		t.Errorf("Result %+v doesn't match vocab size %d and %d merges",
+			result, tokenizer.VocabSize, len(tokenizer.Merges))
+	}
+
+	reachable := New()
+	result, err = reachable.TrainWithResult([]byte("aaabdaaabac"), TrainOptions{TargetVocabSize: 258})
+	if err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !result.Reached() || result.Achieved != 258 || result.Merges != 2 {
+		t.Errorf("Expected the target to be reached with 2 merges, got %+v", result)
+	}
+
+	if _, err := New().TrainWithResult([]byte("abc"), TrainOptions{TargetVocabSize: 10}); err == nil {
+		t.Error("Expected an error for an invalid target")
+	}
+}
+
+func TestTrainWithOptionsSkipWhitespaceMerges(t *testing.T) {
+	var text []byte
+	for i := range 200 {
+		text = append(text, "        "[:1+i%8]...)
+		text = append(text, "\tfoo bar\n\n"...)
+	}
+
+	plain := New()
+	if err := plain.Train(text, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	hasWhitespaceToken := func(tokenizer *Tokenizer) bool {
+		for _, merge := range tokenizer.Merges {
+			if isWhitespace(tokenizer.Vocabulary[merge.Result]) {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasWhitespaceToken(plain) {
+		t.Fatal("Expected plain training to learn a whitespace-only token")
+	}
+
+	tokenizer := New()
+	opts := TrainOptions{TargetVocabSize: 300, SkipWhitespaceMerges: true}
+	if _, err := tokenizer.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if hasWhitespaceToken(tokenizer) {
+		t.Error("Expected no whitespace-only merges")
+	}
+	if len(tokenizer.Merges) == 0 {
+		t.Error("Expected other merges to be learned")
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+}
+
+func TestTrainWithOptionsSampleFraction(t *testing.T) {
+	text := generateVariedText(32 * 1024)
+
+	full := New()
+	if err := full.Train(text, 500); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	whole := New()
+	if _, err := whole.TrainWithOptions(text, TrainOptions{TargetVocabSize: 500, SampleFraction: 1.0}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !equalMerges(whole.Merges, full.Merges) {
+		t.Error("Expected SampleFraction 1.0 to match normal training")
+	}
+
+	opts := TrainOptions{TargetVocabSize: 500, SampleFraction: 0.25, SampleSeed: 7}
+	sampled := New()
+	if _, err := sampled.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(sampled.Merges) == 0 {
+		t.Fatal("Expected merges from the sample")
+	}
+	again := New()
+	if _, err := again.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !equalMerges(again.Merges, sampled.Merges) {
+		t.Error("Expected the same seed to learn the same merges")
+	}
+	if decoded := sampled.Decode(sampled.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+
+	for _, fraction := range []float64{-0.1, 1.5} {
+		if _, err := New().TrainWithOptions(text, TrainOptions{TargetVocabSize: 500, SampleFraction: fraction}); err == nil {
+			t.Errorf("Expected an error for sample fraction %v", fraction)
+		}
+	}
+}
+
+func TestTrainContextCancelled(t *testing.T) {
+	text := generateVariedText(16 * 1024)
+
+	// Cancel from the progress callback so the stop lands mid-training
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokenizer := New()
+	opts := TrainOptions{
+		TargetVocabSize: 1000,
+		ctx:             ctx,
+		Progress: func(merged, target int) {
+			if merged == 10 {
+				cancel()
+			}
+		},
+	}
+	if _, err := tokenizer.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) != 10 {
+		t.Errorf("Expected training to stop after 10 merges, got %d", len(tokenizer.Merges))
+	}
+	if tokenizer.VocabSize != len(tokenizer.Vocabulary) {
+		t.Errorf("VocabSize %d doesn't match %d vocabulary entries", tokenizer.VocabSize, len(tokenizer.Vocabulary))
+	}
+	if err := tokenizer.Validate(); err != nil {
+		t.Errorf("Partially trained tokenizer is inconsistent: %v", err)
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+
+	// The public entry point reports the cancellation
+	if err := tokenizer.TrainContext(ctx, text, 1000); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(tokenizer.Merges) != 10 {
+		t.Errorf("Expected no merges after cancellation, got %d", len(tokenizer.Merges))
+	}
+}
+
+func TestTrainContextCompletes(t *testing.T) {
+	text := generateVariedText(8 * 1024)
+	expected := New()
+	if err := expected.Train(text, 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	tokenizer := New()
+	if err := tokenizer.TrainContext(context.Background(), text, 400); err != nil {
+		t.Fatalf("TrainContext failed: %v", err)
+	}
+	if !equalMerges(tokenizer.Merges, expected.Merges) {
+		t.Error("Expected the same merges as Train")
+	}
+}
+
+func TestTrainWithOptionsNoMergeAcross(t *testing.T) {
+	text := bytes.Repeat([]byte("key: value\nkey: other\n\n"), 50)
+
+	tokenizer := New()
+	opts := TrainOptions{TargetVocabSize: 300, NoMergeAcross: []byte("\n")}
+	if _, err := tokenizer.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) == 0 {
+		t.Fatal("Expected merges within lines")
+	}
+	for _, merge := range tokenizer.Merges {
+		if tokenBytes := tokenizer.Vocabulary[merge.Result]; bytes.IndexByte(tokenBytes, '\n') >= 0 {
+			t.Errorf("Token %d %q contains a newline", merge.Result, tokenBytes)
+		}
+	}
+	for _, id := range tokenizer.Encode(text) {
+		if tokenBytes := tokenizer.Vocabulary[id]; len(tokenBytes) > 1 && bytes.IndexByte(tokenBytes, '\n') >= 0 {
+			t.Errorf("Encoded token %q spans a newline", tokenBytes)
+		}
+	}
+	if decoded := tokenizer.Decode(tokenizer.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Error("Decoded text doesn't match original")
+	}
+}
+
+func TestTrainWithOptionsMergeScorer(t *testing.T) {
+	text := generateVariedText(8 * 1024)
+
+	expected := New()
+	if _, err := expected.TrainWithOptions(text, TrainOptions{TargetVocabSize: 400}); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// Scoring by count reproduces the default objective
+	byCount := New()
+	opts := TrainOptions{
+		TargetVocabSize: 400,
+		MergeScorer: func(_ [2]int, count int, _ *Tokenizer) float64 {
+			return float64(count)
+		},
+	}
+	if _, err := byCount.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !equalMerges(byCount.Merges, expected.Merges) {
+		t.Error("Expected a count scorer to learn the default merges")
+	}
+
+	// Inverting the score picks the rarest pair instead
+	inverted := New()
+	opts.TargetVocabSize = 260
+	opts.MergeScorer = func(_ [2]int, count int, _ *Tokenizer) float64 {
+		return -float64(count)
+	}
+	if _, err := inverted.TrainWithOptions(text, opts); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	first := inverted.Merges[0]
+	if [2]int{first.First, first.Second} == [2]int{expected.Merges[0].First, expected.Merges[0].Second} {
+		t.Errorf("Expected the inverted scorer to choose a different first merge, got %v", first)
+	}
+	if first.Count != 1 {
+		t.Errorf("Expected the inverted scorer to choose a pair seen once, got count %d", first.Count)
+	}
+}
+
+func TestTrainWithOptionsTieBreak(t *testing.T) {
+	// After "ab" merges, every remaining pair occurs once:
+	// ("ab","ab"), ("ab",c), (c,"ab"), ("ab",z) and (z,y)
+	text := []byte("ababcabzy")
+	ab := 256
+
+	cases := []struct {
+		tieBreak TieBreak
+		want     [2]int
+	}{
+		{LowestID, [2]int{'c', ab}},
+		{LongestResult, [2]int{ab, ab}},
+		{ShortestResult, [2]int{'z', 'y'}},
+	}
+	for _, tc := range cases {
+		tokenizer := New()
+		opts := TrainOptions{TargetVocabSize: 258, TieBreak: tc.tieBreak}
+		if _, err := tokenizer.TrainWithOptions(text, opts); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+		if len(tokenizer.Merges) != 2 {
+			t.Fatalf("Expected 2 merges, got %d", len(tokenizer.Merges))
+		}
+		if first := tokenizer.Merges[0]; first.First != 'a' || first.Second != 'b' {
+			t.Fatalf("Expected \"ab\" to merge first, got %v", first)
+		}
+		if got := tokenizer.Merges[1]; [2]int{got.First, got.Second} != tc.want {
+			t.Errorf("TieBreak %d: expected %v, got %v", tc.tieBreak, tc.want, [2]int{got.First, got.Second})
+		}
+	}
+
+	if _, err := New().TrainWithOptions(text, TrainOptions{TargetVocabSize: 258, TieBreak: 99}); err == nil {
+		t.Error("Expected an error for an unknown tie break")
+	}
+}
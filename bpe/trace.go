@@ -0,0 +1,89 @@
+package bpe
+
+// EncodeStep is one step of an encoding trace
+type EncodeStep struct {
+	Merge  Merge // The merge rule applied (pair and resulting token)
+	Tokens []int // Token sequence after every occurrence of the pair was merged
+}
+
+// EncodeTrace encodes text the slow, textbook way and records each merge
+// that changed the token sequence
+//
+// Merges are applied one at a time in the order they were learned; merges
+// with no occurrences are omitted from the trace. The final step's Tokens
+// equal Encode(text) (or the byte-level tokens when no merge applies). This
+// is a teaching and debugging aid and is much slower than Encode.
+func (t *Tokenizer) EncodeTrace(text []byte) []EncodeStep {
+	// Byte-level tokens, with chunk boundaries so merges can't cross them
+	text = t.normalize(text)
+	tokens := make([]int, 0, len(text))
+	if t.Pretokenizer == nil {
+		tokens = t.appendByteTokens(tokens, text)
+	} else {
+		for i, chunk := range t.Pretokenizer(text) {
+			if i > 0 {
+				tokens = append(tokens, chunkBoundary)
+			}
+			tokens = t.appendByteTokens(tokens, chunk)
+		}
+	}
+
+	steps := []EncodeStep{}
+	for _, merge := range t.Merges {
+		result := make([]int, 0, len(tokens))
+		merged := false
+		for i := 0; i < len(tokens); i++ {
+			if i < len(tokens)-1 && tokens[i] == merge.First && tokens[i+1] == merge.Second {
+				result = append(result, merge.Result)
+				merged = true
+				i++ // Skip the second token of the pair
+			} else {
+				result = append(result, tokens[i])
+			}
+		}
+		if !merged {
+			continue
+		}
+
+		tokens = result
+		snapshot := make([]int, 0, len(tokens))
+		for _, id := range tokens {
+			if id != chunkBoundary {
+				snapshot = append(snapshot, id)
+			}
+		}
+		steps = append(steps, EncodeStep{Merge: merge, Tokens: snapshot})
+	}
+
+	return steps
+}
+
+// TokenNode is a node in a token's merge tree
+type TokenNode struct {
+	ID    int
+	Bytes []byte
+	// Left and Right are the tokens merged to form ID, or nil for a leaf:
+	// a base byte token, a special token, or an unknown ID
+	Left, Right *TokenNode
+}
+
+// ExpandToken returns the merge tree of a token, recursing through
+// MergeFor down to base byte tokens
+// Bytes share storage with Vocabulary and must not be modified. A merge
+// whose children don't precede its result (which Validate rejects) is
+// treated as a leaf, so a corrupt vocabulary can't recurse forever.
+func (t *Tokenizer) ExpandToken(id int) TokenNode {
+	node := TokenNode{ID: id}
+	if id >= 0 && id < len(t.Vocabulary) {
+		node.Bytes = t.Vocabulary[id]
+	}
+
+	merge, ok := t.MergeFor(id)
+	if !ok || merge.First >= id || merge.Second >= id {
+		return node
+	}
+	left := t.ExpandToken(merge.First)
+	right := t.ExpandToken(merge.Second)
+	node.Left, node.Right = &left, &right
+	return node
+}
@@ -0,0 +1,91 @@
+package bpe
+
+import (
+	"testing"
+)
+
+func TestEncodeTrace(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	steps := tokenizer.EncodeTrace([]byte("aaa"))
+	if len(steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d: %+v", len(steps), steps)
+	}
+
+	// Step 1: 'a' + 'a' -> 256, leaving [256, 'a']
+	if steps[0].Merge != (Merge{First: 'a', Second: 'a', Result: 256, Count: 2}) {
+		t.Errorf("Unexpected first merge: %+v", steps[0].Merge)
+	}
+	if !equalTokens(steps[0].Tokens, []int{256, 'a'}) {
+		t.Errorf("Expected [256 97] after step 1, got %v", steps[0].Tokens)
+	}
+
+	// Step 2: 256 + 'a' -> 257, leaving [257]
+	if steps[1].Merge != (Merge{First: 256, Second: 'a', Result: 257, Count: 1}) {
+		t.Errorf("Unexpected second merge: %+v", steps[1].Merge)
+	}
+	if !equalTokens(steps[1].Tokens, []int{257}) {
+		t.Errorf("Expected [257] after step 2, got %v", steps[1].Tokens)
+	}
+}
+
+func TestEncodeTraceMatchesEncode(t *testing.T) {
+	for _, pretokenizer := range []func([]byte) [][]byte{nil, GPT2Pretokenizer} {
+		tokenizer := New()
+		tokenizer.Pretokenizer = pretokenizer
+		text := generateText(2048)
+		if err := tokenizer.Train(text, 320); err != nil {
+			t.Fatalf("Training failed: %v", err)
+		}
+
+		input := []byte("the quick brown fox tokenizes the lazy dog")
+		steps := tokenizer.EncodeTrace(input)
+		if len(steps) == 0 {
+			t.Fatal("Expected at least one step")
+		}
+		final := steps[len(steps)-1].Tokens
+		if !equalTokens(final, tokenizer.Encode(input)) {
+			t.Errorf("Final trace step %v differs from Encode %v", final, tokenizer.Encode(input))
+		}
+	}
+}
+
+func TestEncodeTraceNoMerges(t *testing.T) {
+	if steps := New().EncodeTrace([]byte("abc")); len(steps) != 0 {
+		t.Errorf("Expected no steps for an untrained tokenizer, got %+v", steps)
+	}
+}
+
+func TestExpandToken(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaa"), 258); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	// 257 = (256, 'a') and 256 = ('a', 'a')
+	root := tokenizer.ExpandToken(257)
+	if root.ID != 257 || string(root.Bytes) != "aaa" {
+		t.Fatalf("Expected root 257 %q, got %d %q", "aaa", root.ID, root.Bytes)
+	}
+	if root.Left == nil || root.Right == nil {
+		t.Fatal("Expected the root to have two children")
+	}
+	if root.Left.ID != 256 || string(root.Left.Bytes) != "aa" || root.Right.ID != 'a' {
+		t.Errorf("Expected children 256 and 97, got %d and %d", root.Left.ID, root.Right.ID)
+	}
+	for _, leaf := range []*TokenNode{root.Left.Left, root.Left.Right, root.Right} {
+		if leaf == nil || leaf.ID != 'a' || leaf.Left != nil || leaf.Right != nil {
+			t.Errorf("Expected a leaf for byte 'a', got %+v", leaf)
+		}
+	}
+
+	if leaf := tokenizer.ExpandToken('b'); leaf.Left != nil || string(leaf.Bytes) != "b" {
+		t.Errorf("Expected a base byte to be a leaf, got %+v", leaf)
+	}
+	if unknown := tokenizer.ExpandToken(999); unknown.Bytes != nil || unknown.Left != nil {
+		t.Errorf("Expected an empty leaf for an unknown ID, got %+v", unknown)
+	}
+}
@@ -0,0 +1,105 @@
+package bpe
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Validate checks that the tokenizer's fields are consistent with each
+// other and returns an error describing the first problem found
+//
+// It checks that VocabSize matches the vocabulary, that every token other
+// than a special token has bytes, that the lowest IDs are the byte tokens, and
+// that each merge refers to existing, non-special tokens with lower IDs,
+// produces an existing token holding their concatenation, has a higher
+// Result than the merge before it, and doesn't repeat the pair of an
+// earlier merge. IDs must be dense: every ID above the byte tokens is a
+// special token or the result of a merge, so a token no merge produces
+// (such as one Dedupe leaves behind, until Compact) is reported. A
+// tokenizer built by New and Train always passes; this is for vocabularies
+// loaded from files that may have been edited by hand.
+func (t *Tokenizer) Validate() error {
+	if t.VocabSize != len(t.Vocabulary) {
+		return fmt.Errorf("vocab size %d does not match %d vocabulary entries", t.VocabSize, len(t.Vocabulary))
+	}
+	for id := 0; id < t.VocabSize; id++ {
+		if _, special := t.specialTokens[id]; !special && len(t.Vocabulary[id]) == 0 {
+			return fmt.Errorf("token ID %d has no bytes", id)
+		}
+	}
+	base := t.baseVocabSize()
+	for id := 0; id < base && id < t.VocabSize; id++ {
+		if b := t.Vocabulary[id]; len(b) != 1 || (t.alphabet == nil && b[0] != byte(id)) {
+			return fmt.Errorf("byte token %d has bytes %q", id, b)
+		}
+	}
+
+	lastResult := base - 1
+	seen := make(map[[2]int]int, len(t.Merges))
+	for i, merge := range t.Merges {
+		if merge.Result < 0 || merge.Result >= len(t.Vocabulary) {
+			return fmt.Errorf("merge %d result %d is outside the vocabulary", i, merge.Result)
+		}
+		for _, id := range [2]int{merge.First, merge.Second} {
+			if id < 0 || id >= len(t.Vocabulary) {
+				return fmt.Errorf("merge %d references unknown token %d", i, id)
+			}
+			if _, special := t.specialTokens[id]; special {
+				return fmt.Errorf("merge %d references special token %d", i, id)
+			}
+			if id >= merge.Result {
+				return fmt.Errorf("merge %d uses token %d, which does not precede its result %d", i, id, merge.Result)
+			}
+		}
+		if merge.Result <= lastResult {
+			return fmt.Errorf("merge %d result %d does not follow the previous result %d", i, merge.Result, lastResult)
+		}
+		lastResult = merge.Result
+		if j, dup := seen[[2]int{merge.First, merge.Second}]; dup {
+			return duplicateMergeError(i, j, merge)
+		}
+		seen[[2]int{merge.First, merge.Second}] = i
+
+		if _, special := t.specialTokens[merge.Result]; special {
+			return fmt.Errorf("merge %d result %d is a special token", i, merge.Result)
+		}
+		expected := append(append([]byte{}, t.Vocabulary[merge.First]...), t.Vocabulary[merge.Second]...)
+		if !bytes.Equal(t.Vocabulary[merge.Result], expected) {
+			return fmt.Errorf("merge %d result %d has bytes %q, expected %q", i, merge.Result, t.Vocabulary[merge.Result], expected)
+		}
+	}
+
+	// Results increase, so walking them alongside the IDs finds any gap
+	next := 0
+	for id := base; id < t.VocabSize; id++ {
+		if _, special := t.specialTokens[id]; special {
+			continue
+		}
+		for next < len(t.Merges) && t.Merges[next].Result < id {
+			next++
+		}
+		if next == len(t.Merges) || t.Merges[next].Result != id {
+			return fmt.Errorf("token ID %d is neither a special token nor the result of a merge", id)
+		}
+	}
+
+	return nil
+}
+
+// duplicateMerge returns an error for the first merge that repeats the pair
+// of an earlier merge, or nil if every pair is merged once
+func duplicateMerge(merges []Merge) error {
+	seen := make(map[[2]int]int, len(merges))
+	for i, merge := range merges {
+		pair := [2]int{merge.First, merge.Second}
+		if j, dup := seen[pair]; dup {
+			return duplicateMergeError(i, j, merge)
+		}
+		seen[pair] = i
+	}
+	return nil
+}
+
+func duplicateMergeError(i, j int, merge Merge) error {
+	return fmt.Errorf("merge %d repeats the pair (%d, %d) of merge %d", i, merge.First, merge.Second, j)
+}
@@ -0,0 +1,122 @@
+package bpe
+
+import (
+	"strings"
+	"testing"
+)
+
+func trainedForValidate(t *testing.T) *Tokenizer {
+	t.Helper()
+	tokenizer := New()
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 259); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	return tokenizer
+}
+
+func TestValidateAcceptsTrainedTokenizer(t *testing.T) {
+	tokenizer := trainedForValidate(t)
+	tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 261); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if err := tokenizer.Validate(); err != nil {
+		t.Errorf("Expected a trained tokenizer to validate, got %v", err)
+	}
+}
+
+func TestValidateDetectsCorruption(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(tokenizer *Tokenizer)
+		message string
+	}{
+		{
+			name: "dangling merge reference",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Merges[1].Second = 999
+			},
+			message: "unknown token 999",
+		},
+		{
+			name: "empty token",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Vocabulary[257] = nil
+			},
+			message: "token ID 257 has no bytes",
+		},
+		{
+			name: "vocab size mismatch",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.VocabSize++
+			},
+			message: "does not match",
+		},
+		{
+			name: "merge uses a later token",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Merges[0].First = 258
+			},
+			message: "does not precede",
+		},
+		{
+			name: "results out of order",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Merges[1].Result = 256
+			},
+			message: "does not follow",
+		},
+		{
+			name: "result outside the vocabulary",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Merges[len(tokenizer.Merges)-1].Result = 999
+			},
+			message: "result 999 is outside the vocabulary",
+		},
+		{
+			name: "negative result",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Merges[0].Result = -1
+			},
+			message: "result -1 is outside the vocabulary",
+		},
+		{
+			name: "gap in IDs",
+			corrupt: func(tokenizer *Tokenizer) {
+				// Shift the last merge up one ID, leaving an orphan token
+				// in the slot it used to fill
+				last := &tokenizer.Merges[len(tokenizer.Merges)-1]
+				tokenizer.Vocabulary = append(tokenizer.Vocabulary, tokenizer.Vocabulary[last.Result])
+				tokenizer.VocabSize++
+				last.Result++
+			},
+			message: "token ID 258 is neither a special token nor the result of a merge",
+		},
+		{
+			name: "orphan token at the end",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Vocabulary = append(tokenizer.Vocabulary, []byte("zz"))
+				tokenizer.VocabSize++
+			},
+			message: "token ID 259 is neither",
+		},
+		{
+			name: "result bytes wrong",
+			corrupt: func(tokenizer *Tokenizer) {
+				tokenizer.Vocabulary[256] = []byte("zz")
+			},
+			message: "has bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		tokenizer := trainedForValidate(t)
+		tt.corrupt(tokenizer)
+
+		err := tokenizer.Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.message, err)
+		}
+	}
+}
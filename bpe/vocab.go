@@ -0,0 +1,87 @@
+package bpe
+
+import (
+	"math"
+	"slices"
+)
+
+// VocabEntry is a single vocabulary entry: a token ID and its bytes
+type VocabEntry struct {
+	ID    int
+	Bytes []byte
+}
+
+// SortedVocabulary returns the vocabulary as a slice sorted by token ID
+// Vocabulary is already indexed by ID; this pairs each entry with its ID
+// for dumping or display, and predates the switch away from a map.
+// Bytes share storage with Vocabulary and must not be modified.
+func (t *Tokenizer) SortedVocabulary() []VocabEntry {
+	entries := make([]VocabEntry, len(t.Vocabulary))
+	for id, tokenBytes := range t.Vocabulary {
+		entries[id] = VocabEntry{ID: id, Bytes: tokenBytes}
+	}
+	return entries
+}
+
+// IsTrained reports whether the tokenizer has any merges, as opposed to
+// encoding every byte as its own token
+func (t *Tokenizer) IsTrained() bool {
+	return len(t.Merges) > 0
+}
+
+// NumMerges returns the number of merge rules
+func (t *Tokenizer) NumMerges() int {
+	return len(t.Merges)
+}
+
+// MaxTokenBytes returns the length in bytes of the longest token in the
+// vocabulary, e.g. for sizing buffers that must hold any single token
+// It is tracked as tokens are added, so the call is O(1). Special tokens
+// are not counted. Edits made directly to Vocabulary are not seen.
+func (t *Tokenizer) MaxTokenBytes() int {
+	return t.maxTokenBytes
+}
+
+// TokenLengthHistogram maps each token byte length to the number of
+// vocabulary entries of that length
+// Special tokens are not counted since they have no bytes of their own.
+func (t *Tokenizer) TokenLengthHistogram() map[int]int {
+	histogram := make(map[int]int)
+	for id, tokenBytes := range t.Vocabulary {
+		if len(tokenBytes) == 0 || t.IsSpecial(id) {
+			continue
+		}
+		histogram[len(tokenBytes)]++
+	}
+	return histogram
+}
+
+// TokenLengthPercentile returns the token byte length at percentile p (0 to
+// 100) of the vocabulary, using the nearest-rank method
+// p is clamped to [0, 100], so 0 gives the shortest length and 100 the
+// longest. Special tokens are not counted. It returns 0 for an empty
+// vocabulary.
+func (t *Tokenizer) TokenLengthPercentile(p float64) int {
+	histogram := t.TokenLengthHistogram()
+	lengths := make([]int, 0, len(histogram))
+	total := 0
+	for length, count := range histogram {
+		lengths = append(lengths, length)
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	slices.Sort(lengths)
+
+	rank := int(math.Ceil(min(max(p, 0), 100) / 100 * float64(total)))
+	rank = max(rank, 1)
+	seen := 0
+	for _, length := range lengths {
+		seen += histogram[length]
+		if seen >= rank {
+			return length
+		}
+	}
+	return lengths[len(lengths)-1]
+}
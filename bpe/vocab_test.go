@@ -0,0 +1,141 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSortedVocabulary(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train([]byte("low lower lowest newer newest"), 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	entries := tokenizer.SortedVocabulary()
+	if len(entries) != tokenizer.VocabSize {
+		t.Fatalf("Expected %d entries, got %d", tokenizer.VocabSize, len(entries))
+	}
+	for i, entry := range entries {
+		if entry.ID != i {
+			t.Fatalf("Expected ID %d at position %d, got %d", i, i, entry.ID)
+		}
+		if !bytes.Equal(entry.Bytes, tokenizer.Vocabulary[entry.ID]) {
+			t.Errorf("Entry %d: expected %q, got %q", entry.ID, tokenizer.Vocabulary[entry.ID], entry.Bytes)
+		}
+	}
+}
+
+func TestTokenLengthHistogram(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<eos>")
+	if err := tokenizer.Train(generateVariedText(8*1024), 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	histogram := tokenizer.TokenLengthHistogram()
+	// Merges always join two tokens, so every single-byte token is a base byte
+	if histogram[1] != len(tokenizer.alphabetBytes()) {
+		t.Errorf("Expected %d single-byte tokens, got %d", len(tokenizer.alphabetBytes()), histogram[1])
+	}
+	total := 0
+	for length, count := range histogram {
+		if length < 1 {
+			t.Errorf("Unexpected length %d in histogram", length)
+		}
+		total += count
+	}
+	if total != tokenizer.VocabSize-1 {
+		t.Errorf("Expected %d tokens counted (excluding <eos>), got %d", tokenizer.VocabSize-1, total)
+	}
+}
+
+func TestTokenLengthPercentile(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.Train(generateVariedText(8*1024), 400); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if got := tokenizer.TokenLengthPercentile(0); got != 1 {
+		t.Errorf("Expected the 0th percentile to be 1, got %d", got)
+	}
+	// 256 of the 400 tokens are single bytes
+	if got := tokenizer.TokenLengthPercentile(50); got != 1 {
+		t.Errorf("Expected the median to be 1, got %d", got)
+	}
+	longest := 0
+	for _, tokenBytes := range tokenizer.Vocabulary {
+		longest = max(longest, len(tokenBytes))
+	}
+	if got := tokenizer.TokenLengthPercentile(100); got != longest {
+		t.Errorf("Expected the 100th percentile to be %d, got %d", longest, got)
+	}
+	if got := tokenizer.TokenLengthPercentile(90); got < 2 || got > longest {
+		t.Errorf("Expected the 90th percentile between 2 and %d, got %d", longest, got)
+	}
+}
+
+func TestMaxTokenBytes(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<a-long-special-token>")
+	if got := tokenizer.MaxTokenBytes(); got != 1 {
+		t.Errorf("Expected 1 before training, got %d", got)
+	}
+
+	if err := tokenizer.Train(bytes.Repeat([]byte("abcd"), 100), 270); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	longest := 0
+	for _, tokenBytes := range tokenizer.Vocabulary {
+		longest = max(longest, len(tokenBytes))
+	}
+	if longest <= 1 {
+		t.Fatalf("Expected training to learn multi-byte tokens")
+	}
+	if got := tokenizer.MaxTokenBytes(); got != longest {
+		t.Errorf("Expected %d after training, got %d", longest, got)
+	}
+
+	var buf bytes.Buffer
+	if err := tokenizer.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := loaded.MaxTokenBytes(); got != longest {
+		t.Errorf("Expected %d after loading, got %d", longest, got)
+	}
+	if got := tokenizer.Clone().MaxTokenBytes(); got != longest {
+		t.Errorf("Expected %d for a clone, got %d", longest, got)
+	}
+
+	tokenizer.Reset()
+	if got := tokenizer.MaxTokenBytes(); got != 1 {
+		t.Errorf("Expected 1 after Reset, got %d", got)
+	}
+}
+
+func TestIsTrained(t *testing.T) {
+	tokenizer := New()
+	tokenizer.AddSpecialToken("<eos>")
+	if tokenizer.IsTrained() || tokenizer.NumMerges() != 0 {
+		t.Errorf("Expected a fresh tokenizer to be untrained, got %d merges", tokenizer.NumMerges())
+	}
+
+	if err := tokenizer.Train([]byte("aaabdaaabac"), 260); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !tokenizer.IsTrained() {
+		t.Error("Expected a trained tokenizer")
+	}
+	if got := tokenizer.NumMerges(); got != 3 {
+		t.Errorf("Expected 3 merges, got %d", got)
+	}
+
+	tokenizer.Reset()
+	if tokenizer.IsTrained() {
+		t.Error("Expected Reset to leave the tokenizer untrained")
+	}
+}
@@ -0,0 +1,99 @@
+package bpe
+
+import (
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+)
+
+// TrainWeighted learns BPE merges from several documents, counting every
+// pair in docs[i] weights[i] times
+// targetVocabSize is the desired final vocabulary size
+//
+// Use it to let high-quality text count for more (e.g. weight 3) when
+// choosing merges. Merges are applied to each document separately, so no
+// pair is ever formed across a document boundary. A weight of zero ignores
+// the document.
+func (t *Tokenizer) TrainWeighted(docs [][]byte, weights []int, targetVocabSize int) error {
+	opts := TrainOptions{TargetVocabSize: targetVocabSize}
+	if err := opts.validate(t); err != nil {
+		return err
+	}
+	if len(weights) != len(docs) {
+		return fmt.Errorf("got %d weights for %d documents", len(weights), len(docs))
+	}
+	for i, weight := range weights {
+		if weight < 0 {
+			return fmt.Errorf("document %d has negative weight %d", i, weight)
+		}
+	}
+
+	seqs := make([]trainingSequence, 0, len(docs))
+	pairCounts := make(map[[2]int]int)
+	for i, doc := range docs {
+		if weights[i] == 0 {
+			continue
+		}
+
+		tokens := t.trainingTokens(doc)
+		for pair, count := range countPairsParallel(tokens, runtime.GOMAXPROCS(0)) {
+			pairCounts[pair] += count * weights[i]
+		}
+		seqs = append(seqs, trainingSequence{tokens: tokens, weight: weights[i]})
+	}
+
+	t.learnMerges(seqs, pairCounts, opts)
+	return nil
+}
+
+// TrainMulti learns BPE merges from several documents, such as separate
+// files, without forming any pair across a document boundary
+// targetVocabSize is the desired final vocabulary size
+//
+// The documents are concatenated into one training stream with a sentinel
+// between them. Pairs touching the sentinel are never counted, so it can't
+// be merged and never reaches the vocabulary. The result is the same as
+// TrainWeighted with every weight set to 1.
+func (t *Tokenizer) TrainMulti(docs [][]byte, targetVocabSize int) error {
+	opts := TrainOptions{TargetVocabSize: targetVocabSize}
+	if err := opts.validate(t); err != nil {
+		return err
+	}
+
+	tokens := []int{}
+	for i, doc := range docs {
+		if i > 0 {
+			tokens = append(tokens, chunkBoundary)
+		}
+		tokens = append(tokens, t.trainingTokens(doc)...)
+	}
+	pairCounts := countPairsParallel(tokens, runtime.GOMAXPROCS(0))
+
+	t.learnMerges([]trainingSequence{{tokens: tokens, weight: 1}}, pairCounts, opts)
+	return nil
+}
+
+// TrainFromWordCounts learns BPE merges from a word frequency dictionary,
+// as in the original BPE paper, counting the pairs inside each word as many
+// times as the word occurs
+// targetVocabSize is the desired final vocabulary size
+//
+// A dictionary is usually far smaller than the text it was counted from,
+// and training on it gives the same merges as training on that text split
+// into the same words. Merges never cross a word boundary; include any
+// leading space or end-of-word marker in the words themselves if it should
+// take part in merges. A count of zero ignores the word.
+func (t *Tokenizer) TrainFromWordCounts(counts map[string]int, targetVocabSize int) error {
+	words := slices.Sorted(maps.Keys(counts))
+	docs := make([][]byte, len(words))
+	weights := make([]int, len(words))
+	for i, word := range words {
+		if counts[word] < 0 {
+			return fmt.Errorf("word %q has negative count %d", word, counts[word])
+		}
+		docs[i] = []byte(word)
+		weights[i] = counts[word]
+	}
+	return t.TrainWeighted(docs, weights, targetVocabSize)
+}
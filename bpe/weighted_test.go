@@ -0,0 +1,171 @@
+package bpe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrainWeightedFlipsFirstMerge(t *testing.T) {
+	docs := [][]byte{
+		[]byte("ababab"), // "ab" occurs 3 times
+		[]byte("cdcd"),   // "cd" occurs 2 times
+	}
+
+	unweighted := New()
+	if err := unweighted.TrainWeighted(docs, []int{1, 1}, 257); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if m := unweighted.Merges[0]; m.First != 'a' || m.Second != 'b' {
+		t.Errorf("Expected 'a'+'b' first without weighting, got %d+%d", m.First, m.Second)
+	}
+
+	// Weighting the second document 2x makes "cd" count 4 times
+	weighted := New()
+	if err := weighted.TrainWeighted(docs, []int{1, 2}, 257); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if m := weighted.Merges[0]; m.First != 'c' || m.Second != 'd' {
+		t.Errorf("Expected 'c'+'d' first with weighting, got %d+%d", m.First, m.Second)
+	}
+}
+
+func TestTrainWeightedNoCrossDocumentMerges(t *testing.T) {
+	// Concatenated, "xy" + "yx" would make "yy" a candidate
+	docs := [][]byte{[]byte("xy"), []byte("yx")}
+
+	tokenizer := New()
+	if err := tokenizer.TrainWeighted(docs, []int{5, 5}, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, merge := range tokenizer.Merges {
+		if merge.First == 'y' && merge.Second == 'y' {
+			t.Error("Learned a merge across a document boundary")
+		}
+	}
+	if len(tokenizer.Merges) != 2 {
+		t.Errorf("Expected 2 merges (\"xy\" and \"yx\"), got %d", len(tokenizer.Merges))
+	}
+}
+
+func TestTrainWeightedUnitWeightsMatchTrain(t *testing.T) {
+	text := []byte("low lower lowest newer newest")
+
+	expected := New()
+	if err := expected.Train(text, 275); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	weighted := New()
+	if err := weighted.TrainWeighted([][]byte{text}, []int{1}, 275); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if !equalMerges(expected.Merges, weighted.Merges) {
+		t.Errorf("Merges differ.\nExpected: %v\nGot: %v", expected.Merges, weighted.Merges)
+	}
+	if decoded := weighted.Decode(weighted.Encode(text)); !bytes.Equal(decoded, text) {
+		t.Errorf("Decoded text doesn't match original")
+	}
+}
+
+func TestTrainWeightedValidation(t *testing.T) {
+	tokenizer := New()
+	docs := [][]byte{[]byte("ab"), []byte("cd")}
+
+	if err := tokenizer.TrainWeighted(docs, []int{1}, 300); err == nil {
+		t.Error("Expected error for mismatched weights")
+	}
+	if err := tokenizer.TrainWeighted(docs, []int{1, -1}, 300); err == nil {
+		t.Error("Expected error for negative weight")
+	}
+	if err := tokenizer.TrainWeighted(docs, []int{1, 1}, 256); err == nil {
+		t.Error("Expected error for target vocab size <= 256")
+	}
+
+	// Zero-weight documents are ignored
+	if err := tokenizer.TrainWeighted(docs, []int{0, 1}, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if len(tokenizer.Merges) != 1 || tokenizer.Merges[0].First != 'c' {
+		t.Errorf("Expected only 'c'+'d' to be learned, got %v", tokenizer.Merges)
+	}
+}
+
+func TestTrainMultiNoCrossDocumentMerges(t *testing.T) {
+	// Concatenated, "ab" + "ba" would make "bb" a candidate
+	docs := [][]byte{[]byte("ab"), []byte("ba")}
+
+	tokenizer := New()
+	if err := tokenizer.TrainMulti(docs, 300); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	for _, merge := range tokenizer.Merges {
+		if merge.First == 'b' && merge.Second == 'b' {
+			t.Error("Learned a merge across a document boundary")
+		}
+	}
+	for id, tokenBytes := range tokenizer.Vocabulary {
+		if id < 0 || bytes.Contains(tokenBytes, []byte("bb")) {
+			t.Errorf("Unexpected vocabulary entry %d: %q", id, tokenBytes)
+		}
+	}
+	if len(tokenizer.Merges) != 2 {
+		t.Errorf("Expected 2 merges (\"ab\" and \"ba\"), got %d", len(tokenizer.Merges))
+	}
+}
+
+func TestTrainMultiMatchesUnitWeights(t *testing.T) {
+	docs := [][]byte{
+		[]byte("low lower lowest"),
+		[]byte("newer newest"),
+		[]byte("wider widest"),
+	}
+
+	multi := New()
+	if err := multi.TrainMulti(docs, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	weighted := New()
+	if err := weighted.TrainWeighted(docs, []int{1, 1, 1}, 280); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	if !equalMerges(multi.Merges, weighted.Merges) {
+		t.Errorf("Merges differ.\nTrainMulti: %v\nTrainWeighted: %v", multi.Merges, weighted.Merges)
+	}
+}
+
+func TestTrainFromWordCountsMatchesRawText(t *testing.T) {
+	counts := map[string]int{"low": 5, "lower": 2, "newest": 6, "widest": 3, "aaaa": 4}
+
+	var raw []byte
+	for word, count := range counts {
+		for range count {
+			raw = append(raw, word...)
+			raw = append(raw, ' ')
+		}
+	}
+	fromText := New()
+	fromText.Pretokenizer = func(text []byte) [][]byte { return bytes.Fields(text) }
+	if err := fromText.Train(raw, 275); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+
+	fromCounts := New()
+	if err := fromCounts.TrainFromWordCounts(counts, 275); err != nil {
+		t.Fatalf("Training failed: %v", err)
+	}
+	if !equalMerges(fromCounts.Merges, fromText.Merges) {
+		t.Errorf("Merges differ: counts learned %v, raw text learned %v", fromCounts.Merges, fromText.Merges)
+	}
+}
+
+func TestTrainFromWordCountsRejectsNegativeCount(t *testing.T) {
+	tokenizer := New()
+	if err := tokenizer.TrainFromWordCounts(map[string]int{"ab": 2, "cd": -1}, 260); err == nil {
+		t.Error("Expected an error for a negative count")
+	}
+}